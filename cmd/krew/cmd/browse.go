@@ -0,0 +1,73 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// uncategorized groups plugins without categories.
+const uncategorized = "uncategorized"
+
+// browseCmd represents the browse command
+var browseCmd = &cobra.Command{
+	Use:   "browse [category]",
+	Short: "Browse plugins by category",
+	Long: `Browse plugins by category.
+Without arguments, all categories are listed with the number of plugins in
+them. Given a category, the plugins in that category are listed:
+kubectl plugin browse security
+Deprecated plugins are not listed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names, pluginMap, pluginIndex, err := loadAllPlugins()
+		if err != nil {
+			return err
+		}
+		byCategory := make(map[string][]string)
+		for _, name := range withoutDeprecated(names, pluginMap) {
+			categories := pluginMap[name].Spec.Categories
+			if len(categories) == 0 {
+				categories = []string{uncategorized}
+			}
+			for _, c := range categories {
+				byCategory[c] = append(byCategory[c], name)
+			}
+		}
+
+		if len(args) == 0 {
+			counts := make(map[string]string, len(byCategory))
+			for c, plugins := range byCategory {
+				counts[c] = strconv.Itoa(len(plugins))
+			}
+			return printAlignedColumns(os.Stdout, "CATEGORY", "PLUGINS", counts)
+		}
+
+		plugins, ok := byCategory[args[0]]
+		if !ok {
+			return fmt.Errorf("no plugins found in category %q", args[0])
+		}
+		return printPluginTable(os.Stdout, plugins, pluginMap, pluginIndex)
+	},
+	PreRunE: checkIndex,
+	Args:    cobra.MaximumNArgs(1),
+}
+
+func init() {
+	rootCmd.AddCommand(browseCmd)
+}
@@ -0,0 +1,113 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoogleContainerTools/krew/pkg/download"
+
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the download cache",
+	Long: `Manage the download cache.
+Verified plugin archives are kept in a cache, so installing the same version
+again does not download it. Interrupted downloads are kept next to the cache
+until they are resumed. The size of the cache, including the interrupted
+downloads, is limited by "cache.maxSize" in the config file, the least
+recently used files are removed first.`,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the archives in the download cache",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := downloader.Cache.List()
+		if err != nil {
+			return fmt.Errorf("failed to list the download cache, err: %v", err)
+		}
+		return printCacheEntries(os.Stdout, entries)
+	},
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove all archives and interrupted downloads from the download cache",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cache := downloader.Cache
+		entries, err := cache.List()
+		if err != nil {
+			return fmt.Errorf("failed to list the download cache, err: %v", err)
+		}
+		var size int64
+		for _, e := range entries {
+			size += e.Size
+		}
+		partialSize, err := cache.PartialSize()
+		if err != nil {
+			return fmt.Errorf("failed to list the interrupted downloads, err: %v", err)
+		}
+		if err := cache.Clean(); err != nil {
+			return fmt.Errorf("failed to clean the download cache, err: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Removed %d archives (%s) from the download cache\n", len(entries), formatBytes(size))
+		if partialSize > 0 {
+			fmt.Fprintf(os.Stderr, "Removed %s of interrupted downloads\n", formatBytes(partialSize))
+		}
+		return nil
+	},
+}
+
+func printCacheEntries(out io.Writer, entries []download.CacheEntry) error {
+	rowPattern := "%s\t%s\t%s\n"
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, rowPattern, "SHA256", "SIZE", "LAST USED")
+	var total int64
+	for _, e := range entries {
+		fmt.Fprintf(w, rowPattern, e.Sha256, formatBytes(e.Size), e.LastUsed.Format(time.RFC3339))
+		total += e.Size
+	}
+	fmt.Fprintf(w, rowPattern, "TOTAL", formatBytes(total), "")
+	return w.Flush()
+}
+
+// formatBytes formats a size with a binary unit, like "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheListCmd, cacheCleanCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,192 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/GoogleContainerTools/krew/pkg/index"
+	"github.com/GoogleContainerTools/krew/pkg/index/indexoperations"
+	"github.com/GoogleContainerTools/krew/pkg/index/indexscanner"
+	"github.com/GoogleContainerTools/krew/pkg/index/validation"
+
+	"github.com/spf13/cobra"
+)
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage plugin indexes",
+	Long: `Manage plugin indexes.
+Besides the default krew index, plugins can be installed from additional
+indexes. A plugin name is searched in all indexes. If more than one index
+has the plugin, it has to be addressed as "<index>/<plugin>".`,
+}
+
+var indexAddCmd = &cobra.Command{
+	Use:     "add",
+	Short:   "Add a new plugin index",
+	Long:    "Add a new plugin index by cloning the git repository at the given URL.",
+	Example: "  kubectl krew index add my-index https://example.com/my-index.git",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, url := args[0], args[1]
+		if err := indexoperations.AddIndex(paths, name, url); err != nil {
+			return fmt.Errorf("failed to add index %q, err: %v", name, err)
+		}
+		fmt.Fprintf(os.Stderr, "Added index %s\n", name)
+		return nil
+	},
+}
+
+var indexListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configured plugin indexes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		indexes, err := indexoperations.ListIndexes(paths)
+		if err != nil {
+			return fmt.Errorf("failed to list indexes, err: %v", err)
+		}
+		columns := make(map[string]string, len(indexes))
+		for _, idx := range indexes {
+			columns[idx.Name] = idx.URL
+		}
+		return printAlignedColumns(os.Stdout, "INDEX", "URL", columns)
+	},
+}
+
+var indexRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove a plugin index",
+	Long: `Remove a plugin index.
+Plugins installed from the index stay installed, but can't be upgraded until
+the index is added again.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, name := range args {
+			if name == indexoperations.DefaultIndexName {
+				return fmt.Errorf("removing the default index is not allowed")
+			}
+			if err := indexoperations.DeleteIndex(paths, name); err != nil {
+				return fmt.Errorf("failed to remove index %q, err: %v", name, err)
+			}
+			fmt.Fprintf(os.Stderr, "Removed index %s\n", name)
+		}
+		return nil
+	},
+}
+
+func init() {
+	var output *string
+
+	indexValidateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate all plugin manifests of an index repository",
+		Long: `Validate all plugin manifests of an index repository.
+This command is meant for index maintainers. It reports every problem found in
+the manifests of the given index directory and fails if there are any.
+Use "--output json" to get a machine-readable report.`,
+		Example: "  kubectl krew index validate ./my-index",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if *output != "text" && *output != "json" {
+				return fmt.Errorf("unsupported output format %q, must be one of: text, json", *output)
+			}
+			report, err := validation.ValidateIndex(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to validate index, err: %v", err)
+			}
+			if *output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				for _, f := range report.Files {
+					for _, problem := range f.Problems {
+						fmt.Fprintf(os.Stdout, "%s: %s\n", f.File, problem)
+					}
+				}
+			}
+			if n := report.ProblemCount(); n > 0 {
+				return fmt.Errorf("found %d problems in %d of %d files", n, len(report.Files), report.Checked)
+			}
+			fmt.Fprintf(os.Stderr, "Validated %d files\n", report.Checked)
+			return nil
+		},
+	}
+	output = indexValidateCmd.Flags().StringP("output", "o", "text", "Output format, one of: text, json.")
+
+	indexCmd.AddCommand(indexAddCmd, indexListCmd, indexRemoveCmd, indexValidateCmd)
+	rootCmd.AddCommand(indexCmd)
+}
+
+// loadPlugin loads a plugin addressed as "<plugin>" or "<index>/<plugin>" and
+// returns the name of the index it was found in. A plugin without an index
+// is searched in all indexes, the index has to be given only if more than
+// one of them has the plugin.
+func loadPlugin(name string) (indexName string, plugin index.Plugin, err error) {
+	if strings.Contains(name, "/") {
+		indexName, pluginName := indexoperations.ParsePluginName(name)
+		if !indexoperations.IsValidIndexName(indexName) {
+			return "", plugin, fmt.Errorf("index name %q is not allowed", indexName)
+		}
+		plugin, err = indexscanner.LoadPluginFileFromFS(paths.IndexPath(indexName), pluginName)
+		return indexName, plugin, err
+	}
+
+	dirs, err := ioutil.ReadDir(paths.IndexBase())
+	if err != nil {
+		return "", plugin, fmt.Errorf("failed to read index base directory, err: %v", err)
+	}
+	var found []string
+	// The default index is searched first, so its error is returned if no
+	// index has the plugin.
+	plugin, err = indexscanner.LoadPluginFileFromFS(paths.IndexPath(indexoperations.DefaultIndexName), name)
+	if err == nil {
+		found = append(found, indexoperations.DefaultIndexName)
+	} else if !os.IsNotExist(err) {
+		return "", plugin, err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || dir.Name() == indexoperations.DefaultIndexName || !indexoperations.IsValidIndexName(dir.Name()) {
+			continue
+		}
+		p, perr := indexscanner.LoadPluginFileFromFS(paths.IndexPath(dir.Name()), name)
+		if os.IsNotExist(perr) {
+			continue
+		} else if perr != nil {
+			return "", plugin, fmt.Errorf("failed to load plugin %q from index %q, err: %v", name, dir.Name(), perr)
+		}
+		if len(found) == 0 {
+			plugin, err = p, nil
+		}
+		found = append(found, dir.Name())
+	}
+	switch len(found) {
+	case 0:
+		return "", plugin, err
+	case 1:
+		return found[0], plugin, nil
+	default:
+		return "", index.Plugin{}, fmt.Errorf("plugin %q is in the indexes %s, specify it as \"<index>/%s\"", name, strings.Join(found, ", "), name)
+	}
+}
@@ -18,38 +18,59 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/GoogleContainerTools/krew/pkg/index"
-	"github.com/GoogleContainerTools/krew/pkg/index/indexscanner"
-
 	"github.com/GoogleContainerTools/krew/pkg/installation"
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 )
 
+var showPlatformLabels *bool
+
 // infoCmd represents the info command
 var infoCmd = &cobra.Command{
 	Use:   "info",
 	Short: "Info shows plugin details",
 	Long: `Info shows plugin details.
-Use this command to find out about plugin requirements and caveats.`,
+Use this command to find out about plugin requirements and caveats.
+With --platform-labels it shows the labels that platform selectors are
+matched against on this system.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if *showPlatformLabels {
+			printAlignedColumns(os.Stdout, "LABEL", "VALUE", installation.PlatformLabels())
+			return
+		}
+		if len(args) == 0 {
+			glog.Fatal("requires at least 1 arg(s), only received 0")
+		}
 		for _, arg := range args {
-			plugin, err := indexscanner.LoadPluginFileFromFS(paths.IndexPath(), arg)
+			indexName, plugin, err := loadPlugin(arg)
 			if os.IsNotExist(err) {
 				glog.Fatalf("plugin %q not found", arg)
 			} else if err != nil {
 				glog.Fatal(err)
 			}
-			printPluginInfo(os.Stdout, plugin)
+			printPluginInfo(os.Stdout, indexName, plugin)
 		}
 	},
-	PreRunE: checkIndex,
-	Args:    cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if *showPlatformLabels {
+			return nil
+		}
+		return checkIndex(cmd, args)
+	},
 }
 
-func printPluginInfo(out io.Writer, plugin index.Plugin) {
+func printPluginInfo(out io.Writer, indexName string, plugin index.Plugin) {
 	fmt.Fprintf(out, "NAME: %s\n", plugin.Name)
+	fmt.Fprintf(out, "INDEX: %s\n", indexName)
+	if plugin.Spec.Version != "" {
+		fmt.Fprintf(out, "VERSION: %s\n", plugin.Spec.Version)
+	}
+	if version, ok, err := installation.InstalledVersion(paths, plugin.Name); err == nil && ok {
+		fmt.Fprintf(out, "INSTALLED: %s\n", version)
+	}
 	if platform, ok, err := installation.GetMatchingPlatform(plugin); err == nil && ok {
 		if platform.Head != "" {
 			fmt.Fprintf(out, "HEAD: %s\n", platform.Head)
@@ -58,12 +79,54 @@ func printPluginInfo(out io.Writer, plugin index.Plugin) {
 			fmt.Fprintf(out, "URI: %s\n", platform.URI)
 			fmt.Fprintf(out, "SHA256: %s\n", platform.Sha256)
 		}
+		fmt.Fprintf(out, "BINARIES:\n")
+		for _, b := range platform.Executables(plugin.Name) {
+			fmt.Fprintf(out, " * kubectl-%s (%s)\n", b.Name, b.Bin)
+		}
+	}
+	if plugin.Spec.Deprecated {
+		msg := strings.TrimSpace(plugin.Spec.DeprecationMessage)
+		if msg == "" {
+			msg = "yes"
+		}
+		fmt.Fprintf(out, "DEPRECATED: %s\n", msg)
+		if plugin.Spec.ReplacedBy != "" {
+			fmt.Fprintf(out, "REPLACED BY: %s\n", plugin.Spec.ReplacedBy)
+		}
+	}
+	if plugin.Spec.MinKrewVersion != "" {
+		fmt.Fprintf(out, "MIN KREW VERSION: %s\n", plugin.Spec.MinKrewVersion)
+	}
+	if plugin.Spec.Homepage != "" {
+		fmt.Fprintf(out, "HOMEPAGE: %s\n", plugin.Spec.Homepage)
+	}
+	if plugin.Spec.License != "" {
+		fmt.Fprintf(out, "LICENSE: %s\n", plugin.Spec.License)
+	}
+	if len(plugin.Spec.Maintainers) > 0 {
+		fmt.Fprintf(out, "MAINTAINERS:\n")
+		for _, m := range plugin.Spec.Maintainers {
+			if m.Email != "" {
+				fmt.Fprintf(out, " * %s <%s>\n", m.Name, m.Email)
+			} else {
+				fmt.Fprintf(out, " * %s\n", m.Name)
+			}
+		}
+	}
+	if len(plugin.Spec.Tags) > 0 {
+		fmt.Fprintf(out, "TAGS: %s\n", strings.Join(plugin.Spec.Tags, ", "))
+	}
+	if len(plugin.Spec.Categories) > 0 {
+		fmt.Fprintf(out, "CATEGORIES: %s\n", strings.Join(plugin.Spec.Categories, ", "))
 	}
 	if plugin.Spec.Description != "" {
 		fmt.Fprintf(out, "DESCRIPTION: \n%s\n", plugin.Spec.Description)
 	}
-	if plugin.Spec.Version != "" {
-		fmt.Fprintf(out, "VERSION: %s\n", plugin.Spec.Version)
+	if len(plugin.Spec.Dependencies) > 0 {
+		fmt.Fprintf(out, "DEPENDENCIES:\n")
+		for _, dep := range plugin.Spec.Dependencies {
+			fmt.Fprintf(out, " * %s\n", strings.TrimSpace(dep.Name+" "+dep.Version))
+		}
 	}
 	if plugin.Spec.Caveats != "" {
 		fmt.Fprintf(out, "CAVEATS: \n%s\n", plugin.Spec.Caveats)
@@ -71,5 +134,6 @@ func printPluginInfo(out io.Writer, plugin index.Plugin) {
 }
 
 func init() {
+	showPlatformLabels = infoCmd.Flags().Bool("platform-labels", false, "Show the labels of this system that platform selectors are matched against")
 	rootCmd.AddCommand(infoCmd)
 }
@@ -19,7 +19,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/GoogleContainerTools/krew/pkg/download"
+	"github.com/GoogleContainerTools/krew/pkg/index/indexoperations"
 	"github.com/GoogleContainerTools/krew/pkg/index/indexscanner"
 	"github.com/GoogleContainerTools/krew/pkg/installation"
 
@@ -32,13 +35,15 @@ import (
 func init() {
 	var forceHEAD *bool
 	var manifest *string
+	var parallel *int
 
 	// installCmd represents the install command
 	installCmd := &cobra.Command{
 		Use:   "install",
 		Short: "Install a new plugin",
 		Long: `Install a new plugin.
-All plugins will be downloaded and made available to: "kubectl plugin <name>"`,
+All plugins will be downloaded and made available to: "kubectl plugin <name>"
+Up to --parallel plugins are downloaded and extracted at the same time.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var pluginNames = make([]string, len(args))
 			copy(pluginNames, args)
@@ -63,12 +68,29 @@ All plugins will be downloaded and made available to: "kubectl plugin <name>"`,
 			}
 
 			var install []index.Plugin
+			// installIndex maps the canonical "<index>/<plugin>" names to
+			// their index. Plugins are stored by name, so canonical maps a
+			// plugin name to the only index it is installed from.
+			installIndex := make(map[string]string)
+			canonical := make(map[string]string)
 			for _, name := range pluginNames {
-				plugin, err := indexscanner.LoadPluginFileFromFS(paths.IndexPath(), name)
+				indexName, plugin, err := loadPlugin(name)
 				if err != nil {
 					return fmt.Errorf("failed to load plugin %s from index, err: %v", name, err)
 				}
+				canonicalName := indexoperations.CanonicalPluginName(indexName, plugin.Name)
+				if other, ok := canonical[plugin.Name]; ok {
+					if other != canonicalName {
+						return fmt.Errorf("can't install both %s and %s, plugins of the same name from different indexes can't be installed together", other, canonicalName)
+					}
+					continue
+				}
+				if err := checkInstalledIndex(plugin.Name, indexName); err != nil {
+					return err
+				}
 				install = append(install, plugin)
+				installIndex[canonicalName] = indexName
+				canonical[plugin.Name] = canonicalName
 			}
 
 			if *manifest != "" {
@@ -83,6 +105,9 @@ All plugins will be downloaded and made available to: "kubectl plugin <name>"`,
 				if err := plugin.Validate(plugin.Name); err != nil {
 					return fmt.Errorf("failed to validate the plugin file, err %v", err)
 				}
+				if other, ok := canonical[plugin.Name]; ok {
+					return fmt.Errorf("can't install both %s and the plugin from %q", other, *manifest)
+				}
 				install = append(install, plugin)
 			}
 
@@ -97,25 +122,52 @@ All plugins will be downloaded and made available to: "kubectl plugin <name>"`,
 			// Print plugin namesFromFile
 			for _, plugin := range install {
 				fmt.Fprintf(os.Stderr, "Will install plugin: %s\n", plugin.Name)
+				if plugin.Spec.Deprecated {
+					glog.Warning(deprecationNotice(plugin))
+				}
 			}
 
-			var failed []string
 			// Do install
-			for _, plugin := range install {
+			errs := forEachPlugin(*parallel, install, func(plugin index.Plugin, out *pluginOutput) error {
 				glog.V(2).Infof("Installing plugin: %s\n", plugin.Name)
-				err := installation.Install(paths, plugin, *forceHEAD)
+				var pluginFetcher download.Fetcher
+				indexName, ok := installIndex[canonical[plugin.Name]]
+				if !ok {
+					// Plugins from a manifest file are upgraded from the default index.
+					indexName = indexoperations.DefaultIndexName
+					pluginFetcher = sourceFetcher
+				}
+				err := installation.Install(rootContext, paths, plugin, indexName, installation.InstallOpts{
+					ForceHEAD: *forceHEAD,
+					Load:      loadIndexPlugin,
+					InstalledDependency: func(name string) {
+						out.addProgress(name)
+						out.Printf("Installed dependency of %s: %s\n", plugin.Name, name)
+					},
+					Downloader:    out.downloader(),
+					PluginFetcher: pluginFetcher,
+				})
 				if err == installation.ErrIsAlreadyInstalled {
-					glog.Warningf("Skipping plugin %s, it is already installed", plugin.Name)
-					continue
+					out.Printf("Skipping plugin %s, it is already installed\n", plugin.Name)
+					return nil
 				}
 				if err != nil {
-					glog.Warningf("failed to install plugin %q, err: %v", plugin.Name, err)
-					failed = append(failed, plugin.Name)
-					continue
+					out.Printf("Failed to install plugin %s, err: %v\n", plugin.Name, err)
+					return err
 				}
-				fmt.Fprintf(os.Stderr, "Installed plugin: %s\n", plugin.Name)
+				out.Printf("Installed plugin: %s\n", plugin.Name)
 				if plugin.Spec.Caveats != "" {
-					fmt.Fprintf(os.Stderr, "CAVEATS: %s\n", plugin.Spec.Caveats)
+					out.Printf("CAVEATS: %s\n", plugin.Spec.Caveats)
+				}
+				return nil
+			})
+			if rootContext.Err() != nil {
+				return fmt.Errorf("installation was interrupted")
+			}
+			var failed []string
+			for i, err := range errs {
+				if err != nil {
+					failed = append(failed, install[i].Name)
 				}
 			}
 			if len(failed) > 0 {
@@ -128,10 +180,47 @@ All plugins will be downloaded and made available to: "kubectl plugin <name>"`,
 
 	forceHEAD = installCmd.Flags().Bool("HEAD", false, "Force HEAD if versioned and HEAD installs are possible.")
 	manifest = installCmd.Flags().String("source", "", "(Development-only) specify plugin manifest directly.")
+	parallel = installCmd.Flags().Int("parallel", defaultParallel, "Number of plugins that are downloaded and extracted at the same time")
 
 	rootCmd.AddCommand(installCmd)
 }
 
+// checkInstalledIndex fails if the plugin is already installed from another
+// index. Plugins are stored by name, so a plugin of another index with the
+// same name can't be installed next to it.
+func checkInstalledIndex(pluginName, indexName string) error {
+	if _, ok, err := installation.InstalledVersion(paths, pluginName); err != nil || !ok {
+		return err
+	}
+	installedIndex, err := installedPluginIndex(pluginName)
+	if err != nil {
+		return err
+	}
+	if installedIndex != indexName {
+		return fmt.Errorf("can't install %s, plugin %s is already installed from index %q, uninstall it first",
+			indexoperations.CanonicalPluginName(indexName, pluginName), pluginName, installedIndex)
+	}
+	return nil
+}
+
+// loadIndexPlugin loads a plugin manifest from the named index.
+func loadIndexPlugin(indexName, name string) (index.Plugin, error) {
+	return indexscanner.LoadPluginFileFromFS(paths.IndexPath(indexName), name)
+}
+
+// deprecationNotice tells the user that a plugin is deprecated and what to use
+// instead.
+func deprecationNotice(plugin index.Plugin) string {
+	notice := fmt.Sprintf("Plugin %s is deprecated", plugin.Name)
+	if plugin.Spec.DeprecationMessage != "" {
+		notice += ": " + strings.TrimSpace(plugin.Spec.DeprecationMessage)
+	}
+	if plugin.Spec.ReplacedBy != "" {
+		notice += fmt.Sprintf(" (replaced by %s)", plugin.Spec.ReplacedBy)
+	}
+	return notice
+}
+
 func getFileFromArg(file string) (string, error) {
 	if filepath.IsAbs(file) {
 		return file, nil
@@ -0,0 +1,114 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/GoogleContainerTools/krew/pkg/download"
+	"github.com/GoogleContainerTools/krew/pkg/index"
+)
+
+// defaultParallel is the number of plugins that are installed or upgraded at
+// the same time.
+const defaultParallel = 4
+
+// pluginOutput receives the messages about one plugin. When plugins are
+// processed in parallel, the messages are buffered and printed together with
+// the finished download phases once the plugin is done. Until then, the
+// progress of all plugins is shown on a single line on terminals.
+type pluginOutput struct {
+	w        io.Writer
+	label    string
+	progress *summaryProgress
+}
+
+// Printf prints a message about the plugin after its finished download
+// phases.
+func (o *pluginOutput) Printf(format string, a ...interface{}) {
+	o.addProgress(o.label)
+	fmt.Fprintf(o.w, format, a...)
+}
+
+// downloader returns the downloader for the plugin. In parallel runs, it
+// reports the progress to the summary instead of drawing progress bars.
+func (o *pluginOutput) downloader() download.Downloader {
+	d := downloader
+	if o.progress != nil {
+		d.Progress = o.progress
+	}
+	return d
+}
+
+// addProgress adds the finished download phases of the plugin with the label
+// to the output.
+func (o *pluginOutput) addProgress(label string) {
+	if o.progress == nil {
+		return
+	}
+	for _, line := range o.progress.take(label) {
+		fmt.Fprintln(o.w, line)
+	}
+}
+
+// forEachPlugin calls f for each plugin, with at most parallel calls running
+// at the same time. The output of each plugin is printed in the order of the
+// plugins. No more plugins are started once the root context is canceled.
+func forEachPlugin(parallel int, plugins []index.Plugin, f func(plugin index.Plugin, out *pluginOutput) error) []error {
+	errs := make([]error, len(plugins))
+	if parallel <= 1 || len(plugins) <= 1 {
+		for i, plugin := range plugins {
+			if err := rootContext.Err(); err != nil {
+				errs[i] = err
+				continue
+			}
+			errs[i] = f(plugin, &pluginOutput{w: os.Stderr, label: plugin.Name})
+		}
+		return errs
+	}
+
+	// Progress bars of several plugins would be drawn over each other, one
+	// line shows the progress of all plugins instead.
+	summary := newSummaryProgress(os.Stderr, len(plugins))
+
+	outs := make([]*bytes.Buffer, len(plugins))
+	done := make([]chan struct{}, len(plugins))
+	sem := make(chan struct{}, parallel)
+	for i := range plugins {
+		outs[i] = &bytes.Buffer{}
+		done[i] = make(chan struct{})
+		go func(i int) {
+			defer close(done[i])
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			defer summary.pluginDone()
+			if err := rootContext.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+			out := &pluginOutput{w: outs[i], label: plugins[i].Name, progress: summary}
+			errs[i] = f(plugins[i], out)
+			out.addProgress(plugins[i].Name)
+		}(i)
+	}
+	for i := range plugins {
+		<-done[i]
+		summary.print(outs[i])
+	}
+	return errs
+}
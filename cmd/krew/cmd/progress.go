@@ -0,0 +1,284 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleContainerTools/krew/pkg/download"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	progressBarWidth    = 30
+	progressBarInterval = 100 * time.Millisecond
+	progressLogInterval = 5 * time.Second
+	liveLineWidth       = 79
+)
+
+var (
+	phaseRunning = map[download.Phase]string{
+		download.PhaseDownload: "Downloading",
+		download.PhaseVerify:   "Verifying",
+		download.PhaseExtract:  "Extracting",
+		download.PhaseMove:     "Installing",
+	}
+	phaseFinished = map[download.Phase]string{
+		download.PhaseDownload: "Downloaded",
+		download.PhaseVerify:   "Verified",
+		download.PhaseExtract:  "Extracted",
+		download.PhaseMove:     "Installed",
+	}
+)
+
+// newProgressReporter returns a reporter that draws progress bars if out is a
+// terminal, and logs a line every few seconds otherwise.
+func newProgressReporter(out *os.File) download.ProgressReporter {
+	if isatty.IsTerminal(out.Fd()) || isatty.IsCygwinTerminal(out.Fd()) {
+		return &barProgress{out: out}
+	}
+	return &logProgress{out: out, phaseStart: make(map[string]time.Time), lastLine: make(map[string]time.Time)}
+}
+
+// barProgress draws a progress bar that is updated in place.
+type barProgress struct {
+	mu       sync.Mutex
+	out      io.Writer
+	current  string // label and phase of the unfinished bar on the line
+	lastDraw time.Time
+}
+
+func (b *barProgress) Report(p download.Progress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := p.Label + "/" + string(p.Phase)
+	now := time.Now()
+	if key == b.current && !p.Finished && now.Sub(b.lastDraw) < progressBarInterval {
+		return
+	}
+	if b.current != "" && key != b.current {
+		// The previous phase did not finish, keep its last state.
+		fmt.Fprintln(b.out)
+	}
+	fmt.Fprintf(b.out, "\r%s\x1b[K", formatProgressBar(p))
+	b.lastDraw = now
+	b.current = key
+	if p.Finished {
+		fmt.Fprintln(b.out)
+		b.current = ""
+	}
+}
+
+func formatProgressBar(p download.Progress) string {
+	verb := phaseRunning[p.Phase]
+	if p.Finished {
+		verb = phaseFinished[p.Phase]
+	}
+	s := fmt.Sprintf("%s: %-11s", p.Label, verb)
+	switch {
+	case p.Total > 0:
+		filled := int(int64(progressBarWidth) * p.Current / p.Total)
+		bar := strings.Repeat("=", filled)
+		if filled < progressBarWidth {
+			bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+		}
+		s += fmt.Sprintf(" [%s] %3d%% %s/%s", bar, 100*p.Current/p.Total, formatBytes(p.Current), formatBytes(p.Total))
+	case p.Total < 0 && p.Current > 0:
+		s += " " + formatBytes(p.Current)
+	}
+	if p.Mirror != "" {
+		s += " from " + p.Mirror
+	}
+	return s
+}
+
+// logProgress logs the end of each phase and the state of long running phases
+// every few seconds.
+type logProgress struct {
+	mu         sync.Mutex
+	out        io.Writer
+	phaseStart map[string]time.Time
+	lastLine   map[string]time.Time
+}
+
+func (l *logProgress) Report(p download.Progress) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := p.Label + "/" + string(p.Phase)
+	now := time.Now()
+	start, ok := l.phaseStart[key]
+	if !ok {
+		l.phaseStart[key], l.lastLine[key] = now, now
+		return
+	}
+	if p.Finished {
+		delete(l.phaseStart, key)
+		delete(l.lastLine, key)
+		fmt.Fprintln(l.out, formatFinished(p, now.Sub(start)))
+		return
+	}
+	if now.Sub(l.lastLine[key]) < progressLogInterval {
+		return
+	}
+	l.lastLine[key] = now
+	if p.Total > 0 {
+		fmt.Fprintf(l.out, "%s: %s %s of %s (%d%%)\n", p.Label, phaseRunning[p.Phase], formatBytes(p.Current), formatBytes(p.Total), 100*p.Current/p.Total)
+	} else {
+		fmt.Fprintf(l.out, "%s: %s %s\n", p.Label, phaseRunning[p.Phase], formatBytes(p.Current))
+	}
+}
+
+// formatFinished describes a finished phase that took the duration d.
+func formatFinished(p download.Progress, d time.Duration) string {
+	msg := fmt.Sprintf("%s: %s", p.Label, phaseFinished[p.Phase])
+	if p.Total > 0 {
+		msg += " " + formatBytes(p.Total)
+	}
+	if p.Mirror != "" {
+		msg += " from " + p.Mirror
+	}
+	return fmt.Sprintf("%s in %v", msg, d.Round(time.Millisecond))
+}
+
+// summaryProgress keeps the finished phases of each plugin, so they can be
+// printed together when plugins are installed in parallel. On a terminal, a
+// line with the number of finished plugins and the current phases of the
+// running ones is updated in place.
+type summaryProgress struct {
+	mu         sync.Mutex
+	out        io.Writer
+	live       bool
+	phaseStart map[string]time.Time
+	lines      map[string][]string
+	total      int
+	done       int
+	running    []download.Progress // latest event of each running label
+	lastDraw   time.Time
+}
+
+// newSummaryProgress returns the summary for total plugins whose output is
+// printed to out.
+func newSummaryProgress(out *os.File, total int) *summaryProgress {
+	return &summaryProgress{
+		out:        out,
+		live:       isatty.IsTerminal(out.Fd()) || isatty.IsCygwinTerminal(out.Fd()),
+		phaseStart: make(map[string]time.Time),
+		lines:      make(map[string][]string),
+		total:      total,
+	}
+}
+
+func (s *summaryProgress) Report(p download.Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := p.Label + "/" + string(p.Phase)
+	now := time.Now()
+	if s.live {
+		s.setRunning(p)
+		if p.Finished || now.Sub(s.lastDraw) >= progressBarInterval {
+			s.draw(now)
+		}
+	}
+	start, ok := s.phaseStart[key]
+	if !ok {
+		s.phaseStart[key] = now
+		return
+	}
+	if p.Finished {
+		delete(s.phaseStart, key)
+		s.lines[p.Label] = append(s.lines[p.Label], formatFinished(p, now.Sub(start)))
+	}
+}
+
+// setRunning stores the latest event of the label.
+func (s *summaryProgress) setRunning(p download.Progress) {
+	for i := range s.running {
+		if s.running[i].Label == p.Label {
+			s.running[i] = p
+			return
+		}
+	}
+	s.running = append(s.running, p)
+}
+
+// draw replaces the live line.
+func (s *summaryProgress) draw(now time.Time) {
+	fmt.Fprintf(s.out, "\r%s\x1b[K", s.formatLive())
+	s.lastDraw = now
+}
+
+// formatLive describes the finished plugins and the current phases of the
+// running ones, cut to the width of a small terminal.
+func (s *summaryProgress) formatLive() string {
+	line := fmt.Sprintf("%d/%d done", s.done, s.total)
+	for _, p := range s.running {
+		verb := phaseRunning[p.Phase]
+		if p.Finished {
+			verb = phaseFinished[p.Phase]
+		}
+		line += fmt.Sprintf(", %s: %s", p.Label, verb)
+		if p.Total > 0 && !p.Finished {
+			line += fmt.Sprintf(" %d%%", 100*p.Current/p.Total)
+		}
+	}
+	if len(line) > liveLineWidth {
+		line = line[:liveLineWidth-3] + "..."
+	}
+	return line
+}
+
+// take returns the finished phases of a plugin and forgets them.
+func (s *summaryProgress) take(label string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := s.lines[label]
+	delete(s.lines, label)
+	for i := range s.running {
+		if s.running[i].Label == label {
+			s.running = append(s.running[:i], s.running[i+1:]...)
+			break
+		}
+	}
+	return lines
+}
+
+// pluginDone counts a finished plugin.
+func (s *summaryProgress) pluginDone() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done++
+	if s.live {
+		s.draw(time.Now())
+	}
+}
+
+// print prints the output of a finished plugin above the live line.
+func (s *summaryProgress) print(r io.Reader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.live {
+		fmt.Fprint(s.out, "\r\x1b[K")
+	}
+	io.Copy(s.out, r)
+	if s.live && s.done < s.total {
+		s.draw(time.Now())
+	}
+}
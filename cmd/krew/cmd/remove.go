@@ -17,6 +17,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/GoogleContainerTools/krew/pkg/installation"
 
@@ -24,15 +25,28 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var forceRemove *bool
+
 // removeCmd represents the remove command
 var removeCmd = &cobra.Command{
 	Use:   "remove",
 	Short: "Remove a plugin from the system",
 	Long: `Remove a plugin from the system.
-This will delete all plugin related files.`,
+This will delete all plugin related files. Plugins that other installed
+plugins depend on are only removed with --force.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		for _, name := range args {
 			glog.V(4).Infof("Going to remove plugin %s\n", name)
+			dependents, err := installation.Dependents(paths, name)
+			if err != nil {
+				return fmt.Errorf("failed to find plugins that depend on %s, err: %v", name, err)
+			}
+			if len(dependents) > 0 {
+				if !*forceRemove {
+					return fmt.Errorf("plugin %s is required by %s, use --force to remove it anyway", name, strings.Join(dependents, ", "))
+				}
+				glog.Warningf("Removing plugin %s, it is required by %s", name, strings.Join(dependents, ", "))
+			}
 			if err := installation.Remove(paths, name); err != nil {
 				return fmt.Errorf("failed to remove plugin %s, err: %v", name, err)
 			}
@@ -45,5 +59,6 @@ This will delete all plugin related files.`,
 }
 
 func init() {
+	forceRemove = removeCmd.Flags().Bool("force", false, "Remove plugins even if other installed plugins depend on them")
 	rootCmd.AddCommand(removeCmd)
 }
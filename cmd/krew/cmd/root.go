@@ -15,22 +15,35 @@
 package cmd
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
 
+	"github.com/GoogleContainerTools/krew/pkg/download"
 	"github.com/GoogleContainerTools/krew/pkg/environment"
 	"github.com/GoogleContainerTools/krew/pkg/gitutil"
+	"github.com/GoogleContainerTools/krew/pkg/index/indexoperations"
+	"github.com/GoogleContainerTools/krew/pkg/installation"
+	"github.com/GoogleContainerTools/krew/pkg/receipt"
 
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 var (
-	paths               environment.Paths // krew paths used by the process
-	krewExecutedVersion string            // resolved version of krew
+	paths               environment.Paths   // krew paths used by the process
+	krewExecutedVersion string              // resolved version of krew
+	rootContext         context.Context     // canceled when the user interrupts krew
+	downloader          download.Downloader // downloads plugin archives with the settings from the config
+	sourceFetcher       download.Fetcher    // fetches the archives of --source manifests with all schemes
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -46,6 +59,9 @@ You can invoke krew through kubectl with: "kubectl plugin [krew] option..."`,
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	var cancel context.CancelFunc
+	rootContext, cancel = interruptContext()
+	defer cancel()
 	if err := rootCmd.Execute(); err != nil {
 		glog.Fatal(err)
 	}
@@ -65,6 +81,9 @@ func init() {
 		paths.BinPath()); err != nil {
 		glog.Fatal(err)
 	}
+	if err := indexoperations.MigrateLegacyIndex(paths); err != nil {
+		glog.Fatal(err)
+	}
 
 	selfPath, err := os.Executable()
 	if err != nil {
@@ -79,6 +98,24 @@ func init() {
 	SetGlogFlags(krewExecutedVersion != "")
 }
 
+// interruptContext returns a context that is canceled on the first interrupt
+// signal. A second interrupt kills the process.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		select {
+		case <-sigs:
+			fmt.Fprintln(os.Stderr, "Interrupted, cleaning up")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigs)
+	}()
+	return ctx, cancel
+}
+
 // SetGlogFlags will add glog flags to the CLI. This command can be executed multiple times.
 func SetGlogFlags(hidden bool) {
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
@@ -89,7 +126,7 @@ func SetGlogFlags(hidden bool) {
 }
 
 func checkIndex(_ *cobra.Command, _ []string) error {
-	if ok, err := gitutil.IsGitCloned(paths.IndexPath()); err != nil {
+	if ok, err := gitutil.IsGitCloned(paths.IndexPath(indexoperations.DefaultIndexName)); err != nil {
 		return err
 	} else if !ok {
 		return fmt.Errorf("krew local plugin index is not initialized (run \"krew update\")")
@@ -97,6 +134,21 @@ func checkIndex(_ *cobra.Command, _ []string) error {
 	return nil
 }
 
+// installedPluginIndex returns the name of the index an installed plugin came
+// from. Plugins installed before receipts existed are from the default index.
+func installedPluginIndex(name string) (string, error) {
+	r, err := receipt.Load(paths.PluginInstallReceiptPath(name))
+	if os.IsNotExist(err) {
+		return indexoperations.DefaultIndexName, nil
+	} else if err != nil {
+		return "", err
+	}
+	if r.Status.Source.Name == "" {
+		return indexoperations.DefaultIndexName, nil
+	}
+	return r.Status.Source.Name, nil
+}
+
 func ensureDirs(paths ...string) error {
 	for _, p := range paths {
 		glog.V(4).Infof("Ensure creating dir: %q", p)
@@ -109,5 +161,177 @@ func ensureDirs(paths ...string) error {
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	viper.SetEnvPrefix("krew")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
+	viper.SetConfigFile(paths.ConfigPath())
+	if _, err := os.Stat(paths.ConfigPath()); err == nil {
+		if err := viper.ReadInConfig(); err != nil {
+			glog.Fatalf("failed to read config file %q, err: %v", paths.ConfigPath(), err)
+		}
+		glog.V(4).Infof("Using config file %q", paths.ConfigPath())
+	}
+	installation.SetCustomPlatformLabels(viper.GetStringMapString("platformLabels"))
+	cacheSize := int64(installation.DefaultDownloadCacheSize)
+	if s := viper.GetString("cache.maxSize"); s != "" {
+		size, err := resource.ParseQuantity(s)
+		if err != nil {
+			glog.Fatalf("invalid cache.maxSize %q in config file, err: %v", s, err)
+		}
+		cacheSize = size.Value()
+	}
+	limits, err := limitsFromConfig()
+	if err != nil {
+		glog.Fatal(err)
+	}
+	rewrites, err := mirrorRewritesFromConfig()
+	if err != nil {
+		glog.Fatal(err)
+	}
+	fetcher, err := fetcherFromConfig()
+	if err != nil {
+		glog.Fatal(err)
+	}
+	sourceFetcher = fetcher
+	indexSchemes := append(append([]string{}, download.DefaultIndexSchemes...), configList("indexSchemes")...)
+	downloader = download.Downloader{
+		Fetcher:    fetcher.Restrict(indexSchemes),
+		Cache:      installation.DownloadCache(paths, cacheSize),
+		Progress:   newProgressReporter(os.Stderr),
+		Limits:     limits,
+		Rewrites:   rewrites,
+		PartialDir: paths.DownloadPartialPath(),
+	}
+	if err := gitutil.SetOptions(gitutil.Options{
+		CAFiles:    configPathList("tls.caFiles"),
+		CABundle:   paths.GitCABundlePath(),
+		ClientCert: viper.GetString("tls.clientCert"),
+		ClientKey:  viper.GetString("tls.clientKey"),
+		Proxy:      viper.GetString("http.proxy"),
+	}); err != nil {
+		glog.Fatal(fmt.Errorf("invalid TLS settings for git, err: %v", err))
+	}
+}
+
+// configPathList returns a list of files from the config. In environment
+// variables the files are separated like in $PATH.
+func configPathList(key string) []string {
+	if s, ok := viper.Get(key).(string); ok {
+		return filepath.SplitList(s)
+	}
+	return viper.GetStringSlice(key)
+}
+
+// limitsFromConfig returns the download limits with the settings from the
+// config file.
+func limitsFromConfig() (download.Limits, error) {
+	l := download.DefaultLimits()
+	for key, limit := range map[string]*int64{
+		"limits.maxDownloadSize":  &l.MaxDownloadSize,
+		"limits.maxExtractedSize": &l.MaxExtractedSize,
+	} {
+		if s := viper.GetString(key); s != "" {
+			size, err := resource.ParseQuantity(s)
+			if err != nil {
+				return l, fmt.Errorf("invalid %s %q, err: %v", key, s, err)
+			}
+			*limit = size.Value()
+		}
+	}
+	if viper.IsSet("limits.maxEntries") {
+		l.MaxEntries = viper.GetInt("limits.maxEntries")
+	}
+	if viper.IsSet("limits.maxCompressionRatio") {
+		l.MaxCompressionRatio = viper.GetInt64("limits.maxCompressionRatio")
+	}
+	return l, nil
+}
+
+// mirrorRewritesFromConfig returns the mirror rewrites from the config file.
+// In environment variables the rewrites are "<from>=<to>" pairs separated by
+// spaces.
+func mirrorRewritesFromConfig() ([]download.Rewrite, error) {
+	var rewrites []download.Rewrite
+	if s, ok := viper.Get("mirrors").(string); ok {
+		for _, f := range strings.Fields(s) {
+			r, err := download.ParseRewrite(f)
+			if err != nil {
+				return nil, err
+			}
+			rewrites = append(rewrites, r)
+		}
+		return rewrites, nil
+	}
+	var rules []struct{ From, To string }
+	if err := viper.UnmarshalKey("mirrors", &rules); err != nil {
+		return nil, fmt.Errorf("invalid mirrors in config file, err: %v", err)
+	}
+	for i, r := range rules {
+		if r.From == "" || r.To == "" {
+			return nil, fmt.Errorf("mirrors[%d] in config file needs from and to", i)
+		}
+		rewrites = append(rewrites, download.Rewrite{From: r.From, To: r.To})
+	}
+	return rewrites, nil
+}
+
+// fetcherFromConfig returns the fetchers for all URI schemes, including the
+// helper commands from the config file. Plugins of an index can only use the
+// schemes that are allowed in indexSchemes.
+func fetcherFromConfig() (download.Schemes, error) {
+	h, err := httpFetcherFromConfig()
+	if err != nil {
+		return nil, err
+	}
+	schemes := download.NewSchemes(h)
+	schemes["oci"] = &download.OCIFetcher{HTTP: h, PlainHTTP: configList("oci.plainHTTP")}
+	for scheme, command := range viper.GetStringMapString("fetchers") {
+		helper, err := download.ParseHelperFetcher(command)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fetcher for scheme %q, err: %v", scheme, err)
+		}
+		schemes[strings.ToLower(scheme)] = helper
+	}
+	return schemes, nil
+}
+
+// configList returns a list from the config. In environment variables the
+// items are separated by spaces.
+func configList(key string) []string {
+	if s, ok := viper.Get(key).(string); ok {
+		return strings.Fields(s)
+	}
+	return viper.GetStringSlice(key)
+}
+
+// httpFetcherFromConfig returns the fetcher for plugin archives with the
+// settings from the config file.
+func httpFetcherFromConfig() (*download.HTTPFetcher, error) {
+	f := download.NewHTTPFetcher()
+	tlsConfig, err := download.TLSOptions{
+		CAFiles:    configPathList("tls.caFiles"),
+		ClientCert: viper.GetString("tls.clientCert"),
+		ClientKey:  viper.GetString("tls.clientKey"),
+	}.Config()
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS settings, err: %v", err)
+	}
+	f.TLSConfig = tlsConfig
+	if s := viper.GetString("http.proxy"); s != "" {
+		proxy, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http.proxy %q, err: %v", s, err)
+		}
+		f.Proxy = proxy
+	}
+	if viper.IsSet("http.retries") {
+		f.Retries = viper.GetInt("http.retries")
+	}
+	if viper.IsSet("http.connectTimeout") {
+		f.ConnectTimeout = viper.GetDuration("http.connectTimeout")
+	}
+	if viper.IsSet("http.readTimeout") {
+		f.ReadTimeout = viper.GetDuration("http.readTimeout")
+	}
+	return f, nil
 }
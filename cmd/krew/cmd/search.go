@@ -16,10 +16,12 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"text/tabwriter"
 
+	"github.com/GoogleContainerTools/krew/pkg/index/indexoperations"
 	"github.com/GoogleContainerTools/krew/pkg/index/indexscanner"
 
 	"github.com/GoogleContainerTools/krew/pkg/index"
@@ -28,27 +30,35 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	searchTags       *[]string
+	searchDeprecated *bool
+)
+
 // searchCmd represents the search command
 var searchCmd = &cobra.Command{
 	Use:   "search",
 	Short: "Discover plugins in your local index using fuzzy search",
 	Long: `Discover plugins in your local index using fuzzy search.
-Search accepts a list of words as options.`,
+Search accepts a list of words as options. Use --tag to only show plugins
+that have all of the given tags. Deprecated plugins are only shown with
+--deprecated.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		plugins, err := indexscanner.LoadPluginListFromFS(paths.IndexPath())
+		names, pluginMap, pluginIndex, err := loadAllPlugins()
 		if err != nil {
-			return fmt.Errorf("failed to load the index, err %v", err)
+			return err
 		}
-		names := make([]string, len(plugins.Items))
-		pluginMap := make(map[string]index.Plugin, len(plugins.Items))
-		for i, p := range plugins.Items {
-			names[i] = p.Name
-			pluginMap[p.Name] = p
+		if !*searchDeprecated {
+			names = withoutDeprecated(names, pluginMap)
 		}
-
-		installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
-		if err != nil {
-			return fmt.Errorf("failed to load installed plugins, err: %v", err)
+		if len(*searchTags) > 0 {
+			var tagged []string
+			for _, name := range names {
+				if hasAllTags(pluginMap[name], *searchTags) {
+					tagged = append(tagged, name)
+				}
+			}
+			names = tagged
 		}
 
 		var matchNames []string
@@ -65,28 +75,108 @@ Search accepts a list of words as options.`,
 		if len(matchNames) == 0 {
 			return nil
 		}
+		return printPluginTable(os.Stdout, matchNames, pluginMap, pluginIndex)
+	},
+	PreRunE: checkIndex,
+}
+
+// loadAllPlugins loads the plugins of all indexes. It returns the canonical
+// plugin names, the plugins and their index names by canonical name.
+func loadAllPlugins() ([]string, map[string]index.Plugin, map[string]string, error) {
+	indexes, err := indexoperations.ListIndexes(paths)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list indexes, err: %v", err)
+	}
+	var names []string
+	var loadErrors int
+	pluginMap := make(map[string]index.Plugin)
+	pluginIndex := make(map[string]string)
+	for _, idx := range indexes {
+		result, err := indexscanner.LoadPluginListFromFS(paths.IndexPath(idx.Name))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load the index %q, err %v", idx.Name, err)
+		}
+		loadErrors += len(result.Errors)
+		for _, p := range result.Plugins.Items {
+			name := indexoperations.CanonicalPluginName(idx.Name, p.Name)
+			names = append(names, name)
+			pluginMap[name] = p
+			pluginIndex[name] = idx.Name
+		}
+	}
+
+	if loadErrors > 0 {
+		fmt.Fprintf(os.Stderr, "%d manifests could not be loaded (run with -v=1 for details)\n", loadErrors)
+	}
+	return names, pluginMap, pluginIndex, nil
+}
 
-		rowPattern := "%s\t%s\t%s\n"
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-		fmt.Fprintf(w, rowPattern, "NAME", "DESCRIPTION", "STATUS")
-		for _, name := range matchNames {
-			plugin := pluginMap[name]
-			var status string
-			if _, ok := installed[name]; ok {
+// printPluginTable prints the name, description and installation status of
+// the named plugins.
+func printPluginTable(out io.Writer, names []string, pluginMap map[string]index.Plugin, pluginIndex map[string]string) error {
+	installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
+	if err != nil {
+		return fmt.Errorf("failed to load installed plugins, err: %v", err)
+	}
+
+	rowPattern := "%s\t%s\t%s\n"
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, rowPattern, "NAME", "DESCRIPTION", "STATUS")
+	for _, name := range names {
+		plugin := pluginMap[name]
+		var status string
+		if _, ok := installed[plugin.Name]; ok {
+			indexName, err := installedPluginIndex(plugin.Name)
+			if err != nil {
+				return fmt.Errorf("failed to read the receipt of plugin %s, err: %v", plugin.Name, err)
+			}
+			if indexName == pluginIndex[name] {
 				status = "installed"
-			} else if _, ok, err := installation.GetMatchingPlatform(plugin); err != nil {
-				return fmt.Errorf("failed to get the matching platform for plugin %s, err: %v", name, err)
-			} else if ok {
-				status = "available"
 			} else {
-				status = "unavailable"
+				status = "unavailable (name taken)"
 			}
-			fmt.Fprintf(w, rowPattern, name, limitString(plugin.Spec.ShortDescription, 50), status)
+		} else if plugin.Spec.Deprecated {
+			status = "deprecated"
+		} else if _, ok := index.CheckKrewVersion(plugin).(*index.KrewVersionError); ok {
+			status = "requires newer krew"
+		} else if _, ok, err := installation.GetMatchingPlatform(plugin); err != nil {
+			return fmt.Errorf("failed to get the matching platform for plugin %s, err: %v", name, err)
+		} else if ok {
+			status = "available"
+		} else {
+			status = "unavailable"
 		}
-		w.Flush()
-		return nil
-	},
-	PreRunE: checkIndex,
+		fmt.Fprintf(w, rowPattern, name, limitString(plugin.Spec.ShortDescription, 50), status)
+	}
+	return w.Flush()
+}
+
+// withoutDeprecated filters deprecated plugins from the plugin names.
+func withoutDeprecated(names []string, pluginMap map[string]index.Plugin) []string {
+	var filtered []string
+	for _, name := range names {
+		if !pluginMap[name].Spec.Deprecated {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered
+}
+
+// hasAllTags checks if the plugin has all tags, ignoring case.
+func hasAllTags(plugin index.Plugin, tags []string) bool {
+	for _, want := range tags {
+		var found bool
+		for _, tag := range plugin.Spec.Tags {
+			if strings.EqualFold(tag, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 func limitString(s string, length int) string {
@@ -97,5 +187,7 @@ func limitString(s string, length int) string {
 }
 
 func init() {
+	searchTags = searchCmd.Flags().StringSlice("tag", nil, "Only show plugins with this tag, can be repeated")
+	searchDeprecated = searchCmd.Flags().Bool("deprecated", false, "Include deprecated plugins")
 	rootCmd.AddCommand(searchCmd)
 }
@@ -19,7 +19,10 @@ import (
 	"os"
 
 	"github.com/GoogleContainerTools/krew/pkg/gitutil"
+	"github.com/GoogleContainerTools/krew/pkg/index/indexoperations"
+	"github.com/GoogleContainerTools/krew/pkg/index/indexscanner"
 
+	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 )
 
@@ -29,16 +32,39 @@ const IndexURI = "https://github.com/GoogleContainerTools/krew-index.git"
 // updateCmd represents the update command
 var updateCmd = &cobra.Command{
 	Use:   "update",
-	Short: "Update local plugin index",
-	Long: `Update local plugin index.
+	Short: "Update local plugin indexes",
+	Long: `Update local plugin indexes.
 Fetch the newest version of Krew and all formulae from GitHub using git(1) and
-perform any necessary migrations.`,
+perform any necessary migrations. Indexes added with "krew index add" are
+updated as well.`,
 	RunE: ensureUpdated,
 }
 
 func ensureUpdated(_ *cobra.Command, _ []string) error {
-	if err := gitutil.EnsureUpdated(IndexURI, paths.IndexPath()); err != nil {
-		return fmt.Errorf("failed to ensure that the index path %q is updated, err: %v", paths.IndexPath(), err)
+	defaultPath := paths.IndexPath(indexoperations.DefaultIndexName)
+	if err := gitutil.EnsureUpdated(IndexURI, defaultPath); err != nil {
+		return fmt.Errorf("failed to ensure that the index path %q is updated, err: %v", defaultPath, err)
+	}
+
+	indexes, err := indexoperations.ListIndexes(paths)
+	if err != nil {
+		return fmt.Errorf("failed to list indexes, err: %v", err)
+	}
+	var failed []string
+	for _, idx := range indexes {
+		if idx.Name != indexoperations.DefaultIndexName {
+			if err := gitutil.EnsureUpdated(idx.URL, paths.IndexPath(idx.Name)); err != nil {
+				glog.Warningf("failed to update index %q, err: %v", idx.Name, err)
+				failed = append(failed, idx.Name)
+				continue
+			}
+		}
+		if err := indexscanner.UpdateCache(paths.IndexPath(idx.Name)); err != nil {
+			glog.Warningf("failed to update the cache of index %q, err: %v", idx.Name, err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to update some indexes: %+v", failed)
 	}
 	fmt.Fprintln(os.Stderr, "Updated index")
 	return nil
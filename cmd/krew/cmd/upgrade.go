@@ -15,16 +15,26 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/GoogleContainerTools/krew/pkg/index"
 	"github.com/GoogleContainerTools/krew/pkg/index/indexscanner"
 	"github.com/GoogleContainerTools/krew/pkg/installation"
 
 	"github.com/golang/glog"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
+var (
+	allowDowngrade  *bool
+	migrate         *bool
+	upgradeParallel *int
+)
+
 // upgradeCmd represents the upgrade command
 var upgradeCmd = &cobra.Command{
 	Use:   "upgrade",
@@ -32,18 +42,23 @@ var upgradeCmd = &cobra.Command{
 	Long: `Upgrade installed plugins to a newer version.
 This will reinstall all plugins that have a newer version in the local index.
 Use "kubectl plugin update" to renew the index. All plugins that rely on HEAD
-will always be installed.
+will always be installed. Plugins are not downgraded to an older version
+unless --allow-downgrade is given.
+Deprecated plugins that have a replacement can be migrated to it, which
+installs the replacement and removes the old plugin. Use --migrate to migrate
+without asking.
+Up to --parallel plugins are downloaded and extracted at the same time.
 To only upgrade single plugins provide them as arguments:
 kubectl plugin upgrade foo bar"`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var ignoreUpgraded bool
 		var pluginNames []string
+		installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
+		if err != nil {
+			return fmt.Errorf("failed to find all installed versions, err: %v", err)
+		}
 		// Upgrade all plugins.
 		if len(args) == 0 {
-			installed, err := installation.ListInstalledPlugins(paths.InstallPath(), paths.BinPath())
-			if err != nil {
-				return fmt.Errorf("failed to find all installed versions, err: %v", err)
-			}
 			for name := range installed {
 				pluginNames = append(pluginNames, name)
 			}
@@ -52,28 +67,139 @@ kubectl plugin upgrade foo bar"`,
 			pluginNames = args
 		}
 
+		// Migrations ask the user, so they are done before the upgrades run
+		// in parallel.
+		var upgrade []index.Plugin
+		upgradeIndex := make(map[string]string)
 		for _, name := range pluginNames {
-			plugin, err := indexscanner.LoadPluginFileFromFS(paths.IndexPath(), name)
+			indexName, err := installedPluginIndex(name)
 			if err != nil {
-				return fmt.Errorf("failed to load the index file for plugin %s, err: %v", plugin.Name, err)
+				return fmt.Errorf("failed to read the receipt of plugin %s, err: %v", name, err)
+			}
+			plugin, err := indexscanner.LoadPluginFileFromFS(paths.IndexPath(indexName), name)
+			if _, ok := err.(*index.KrewVersionError); ignoreUpgraded && ok {
+				fmt.Fprintf(os.Stderr, "Skipping plugin %s, %v\n", name, err)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load the index file for plugin %s from index %q, err: %v", name, indexName, err)
+			}
+
+			if plugin.Spec.Deprecated {
+				fmt.Fprintln(os.Stderr, deprecationNotice(plugin))
+				if plugin.Spec.ReplacedBy != "" {
+					migrated, err := offerMigration(plugin, indexName)
+					if err != nil {
+						return err
+					}
+					if migrated {
+						continue
+					}
+				}
 			}
 
+			upgrade = append(upgrade, plugin)
+			upgradeIndex[plugin.Name] = indexName
+		}
+
+		errs := forEachPlugin(*upgradeParallel, upgrade, func(plugin index.Plugin, out *pluginOutput) error {
 			glog.V(2).Infof("Upgrading plugin: %s\n", plugin.Name)
-			err = installation.Upgrade(paths, plugin, krewExecutedVersion)
+			err := installation.Upgrade(rootContext, paths, plugin, upgradeIndex[plugin.Name], krewExecutedVersion, installation.UpgradeOpts{
+				AllowDowngrade: *allowDowngrade,
+				Load:           loadIndexPlugin,
+				Downloader:     out.downloader(),
+			})
 			if ignoreUpgraded && err == installation.ErrIsAlreadyUpgraded {
-				fmt.Fprintf(os.Stderr, "Skipping plugin %s, it is already on the newest version\n", plugin.Name)
-				continue
+				out.Printf("Skipping plugin %s, it is already on the newest version\n", plugin.Name)
+				return nil
+			}
+			if ignoreUpgraded && err == installation.ErrIsDowngrade {
+				out.Printf("Skipping plugin %s, the installed version %s is newer than %s in the index\n", plugin.Name, installed[plugin.Name], plugin.Spec.Version)
+				return nil
 			}
+			if _, ok := err.(*index.KrewVersionError); ignoreUpgraded && ok {
+				out.Printf("Skipping plugin %s, %v\n", plugin.Name, err)
+				return nil
+			}
+			if err != nil {
+				out.Printf("Failed to upgrade plugin %s, err: %v\n", plugin.Name, err)
+				return err
+			}
+			newVersion, _, err := installation.InstalledVersion(paths, plugin.Name)
+			if err != nil {
+				return fmt.Errorf("failed to read the new version of plugin %q, err: %v", plugin.Name, err)
+			}
+			out.Printf("Upgraded plugin: %s (%s -> %s)\n", plugin.Name, installed[plugin.Name], newVersion)
+			return nil
+		})
+		if rootContext.Err() != nil {
+			return fmt.Errorf("upgrade was interrupted")
+		}
+		var failed []string
+		for i, err := range errs {
 			if err != nil {
-				return fmt.Errorf("failed to upgrade plugin %q, err: %v", plugin.Name, err)
+				failed = append(failed, upgrade[i].Name)
 			}
-			fmt.Fprintf(os.Stderr, "Upgraded plugin: %s\n", plugin.Name)
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to upgrade some plugins: %+v", failed)
 		}
 		return nil
 	},
 	PreRunE: ensureUpdated,
 }
 
+// offerMigration migrates a deprecated plugin to its replacement if the user
+// agrees. It returns true if the plugin was migrated.
+func offerMigration(plugin index.Plugin, indexName string) (bool, error) {
+	replacementIndex, replacementName := index.SplitPluginRef(plugin.Spec.ReplacedBy)
+	if replacementIndex == "" {
+		replacementIndex = indexName
+	}
+	if !*migrate {
+		if !(isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())) {
+			fmt.Fprintf(os.Stderr, "Run \"kubectl plugin upgrade --migrate %s\" to replace it with %s\n", plugin.Name, replacementName)
+			return false, nil
+		}
+		if !confirm(fmt.Sprintf("Replace plugin %s with %s?", plugin.Name, replacementName)) {
+			return false, nil
+		}
+	}
+
+	dependents, err := installation.Dependents(paths, plugin.Name)
+	if err != nil {
+		return false, fmt.Errorf("failed to find plugins that depend on %s, err: %v", plugin.Name, err)
+	}
+	if len(dependents) > 0 {
+		fmt.Fprintf(os.Stderr, "Not migrating plugin %s, it is required by %s\n", plugin.Name, strings.Join(dependents, ", "))
+		return false, nil
+	}
+
+	replacement, err := loadIndexPlugin(replacementIndex, replacementName)
+	if err != nil {
+		return false, fmt.Errorf("failed to load replacement %s of plugin %s, err: %v", plugin.Spec.ReplacedBy, plugin.Name, err)
+	}
+	if err := installation.Migrate(rootContext, paths, plugin.Name, replacement, replacementIndex, installation.InstallOpts{Load: loadIndexPlugin, Downloader: downloader}); err != nil {
+		return false, fmt.Errorf("failed to migrate plugin %s, err: %v", plugin.Name, err)
+	}
+	fmt.Fprintf(os.Stderr, "Migrated plugin: %s -> %s\n", plugin.Name, replacement.Name)
+	return true, nil
+}
+
+// confirm asks the user a yes or no question on the terminal.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
 func init() {
+	migrate = upgradeCmd.Flags().Bool("migrate", false, "Replace deprecated plugins with their replacement without asking")
+	upgradeParallel = upgradeCmd.Flags().Int("parallel", defaultParallel, "Number of plugins that are downloaded and extracted at the same time")
+	allowDowngrade = upgradeCmd.Flags().Bool("allow-downgrade", false, "Install the version from the index even if it is older than the installed version")
 	rootCmd.AddCommand(upgradeCmd)
 }
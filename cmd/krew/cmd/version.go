@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/GoogleContainerTools/krew/pkg/index/indexoperations"
 	"github.com/GoogleContainerTools/krew/pkg/version"
 	"github.com/spf13/cobra"
 )
@@ -30,7 +31,7 @@ var versionCmd = &cobra.Command{
 ExecutedVersion is the version of the currently executed binary. This is detected through the path.
 IsPlugin is true if the binary is executed as a plugin.
 BasePath is the root path for all krew related binaries.
-IndexPath is the path to the index repo see git(1).
+IndexPath is the path to the default index repo see git(1).
 IndexURI is the URI where the index is updated from.
 InstallPath is the base path for all plugin installations.
 DownloadPath is the path used to store download binaries.`,
@@ -41,7 +42,7 @@ DownloadPath is the path used to store download binaries.`,
 			"GitTag":          version.GitTag(),
 			"GitCommit":       version.GitCommit(),
 			"BasePath":        paths.BasePath(),
-			"IndexPath":       paths.IndexPath(),
+			"IndexPath":       paths.IndexPath(indexoperations.DefaultIndexName),
 			"IndexURI":        IndexURI,
 			"InstallPath":     paths.InstallPath(),
 			"DownloadPath":    paths.DownloadPath(),
@@ -0,0 +1,123 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// Formats of downloaded files.
+const (
+	formatZIP    = "zip"
+	formatGzip   = "tar.gz"
+	formatBzip2  = "tar.bz2"
+	formatXZ     = "tar.xz"
+	formatTAR    = "tar"
+	formatBinary = "binary"
+)
+
+var (
+	archiveMagic = []struct {
+		offset int
+		magic  []byte
+		format string
+	}{
+		{0, []byte("PK\x03\x04"), formatZIP},
+		{0, []byte("PK\x05\x06"), formatZIP}, // empty zip
+		{0, []byte{0x1f, 0x8b}, formatGzip},
+		{0, []byte("BZh"), formatBzip2},
+		{0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, formatXZ},
+		{257, []byte("ustar"), formatTAR},
+	}
+
+	archiveSuffixes = []struct {
+		suffix string
+		format string
+	}{
+		{".zip", formatZIP},
+		{".tar.gz", formatGzip},
+		{".tgz", formatGzip},
+		{".tar.bz2", formatBzip2},
+		{".tbz2", formatBzip2},
+		{".tar.xz", formatXZ},
+		{".txz", formatXZ},
+		{".tar", formatTAR},
+	}
+)
+
+// archiveName returns the file name of a download URI, without the query.
+// OCI artifacts are named by the layer title in the fragment.
+func archiveName(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "oci" && u.Fragment != "" {
+		return path.Base(u.Fragment)
+	} else if err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	return path.Base(uri)
+}
+
+// detectArchiveFormat detects the format of a download by the magic bytes at
+// the start of the file. If the bytes are unknown, the format is inferred
+// from the file name. A file that is no archive is only a plain binary if it
+// is marked as bare or named like an executable, so that a broken or unknown
+// archive is not installed as the plugin.
+func detectArchiveFormat(filename string, bare bool, r io.ReaderAt) (string, error) {
+	header := make([]byte, 262)
+	n, _ := r.ReadAt(header, 0)
+	header = header[:n]
+	for _, m := range archiveMagic {
+		if len(header) >= m.offset+len(m.magic) && bytes.Equal(header[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.format, nil
+		}
+	}
+	name := strings.ToLower(filename)
+	for _, s := range archiveSuffixes {
+		if strings.HasSuffix(name, s.suffix) {
+			return s.format, nil
+		}
+	}
+	if bare || strings.HasSuffix(name, ".exe") {
+		return formatBinary, nil
+	}
+	return "", fmt.Errorf("unsupported archive format of %q", filename)
+}
+
+// extractBinary writes a download that is not an archive as an executable
+// file into the target directory.
+func extractBinary(targetDir, filename string, in io.Reader, limiter *extractLimiter) error {
+	if filename == "" || filename == "." || filename == ".." || filename == "/" || strings.ContainsAny(filename, `/\`) {
+		return fmt.Errorf("cannot infer a file name for the download from %q", filename)
+	}
+	path := filepath.Join(targetDir, filename)
+	glog.V(4).Infof("Writing binary download to %q", path)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create file %q: %+v", path, err)
+	}
+	if _, err := io.Copy(f, limiter.reader(in)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write binary download: %+v", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,139 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_archiveName(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: "https://example.com/foo.tar.gz", want: "foo.tar.gz"},
+		{uri: "https://example.com/dl/foo.zip?raw=true&token=a/b", want: "foo.zip"},
+		{uri: "https://example.com/foo.tgz#fragment", want: "foo.tgz"},
+		{uri: "foo.tar.xz", want: "foo.tar.xz"},
+		{uri: "oci://ghcr.io/foo/plugins:v1#foo.tar.gz", want: "foo.tar.gz"},
+	}
+	for _, tt := range tests {
+		if got := archiveName(tt.uri); got != tt.want {
+			t.Errorf("archiveName(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func Test_detectArchiveFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		bare     bool
+		content  string
+		want     string
+		wantErr  bool
+	}{
+		{filename: "download", content: "PK\x03\x04rest", want: formatZIP},
+		{filename: "download", content: "\x1f\x8brest", want: formatGzip},
+		{filename: "download", content: "BZh91AY", want: formatBzip2},
+		{filename: "download", content: "\xfd7zXZ\x00rest", want: formatXZ},
+		{filename: "download", content: strings.Repeat("\x00", 257) + "ustar\x0000", want: formatTAR},
+		{filename: "foo.TGZ", content: "unknown", want: formatGzip},
+		{filename: "foo.tar", content: "old tar without magic", want: formatTAR},
+		{filename: "foo.zip", content: "\x1f\x8b", want: formatGzip},
+		{filename: "kubectl-foo", bare: true, content: "#!/bin/sh", want: formatBinary},
+		{filename: "kubectl-foo", bare: true, content: "", want: formatBinary},
+		{filename: "kubectl-foo.EXE", content: "MZ", want: formatBinary},
+		{filename: "kubectl-foo", bare: true, content: "PK\x03\x04rest", want: formatZIP},
+		{filename: "kubectl-foo", content: "#!/bin/sh", wantErr: true},
+		{filename: "foo.rar", content: "Rar!\x1a\x07", wantErr: true},
+		{filename: "download", content: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := detectArchiveFormat(tt.filename, tt.bare, strings.NewReader(tt.content))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("detectArchiveFormat(%q, %v, %q) error = %v, wantErr %v", tt.filename, tt.bare, tt.content, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("detectArchiveFormat(%q, %v, %q) = %q, want %q", tt.filename, tt.bare, tt.content, got, tt.want)
+		}
+	}
+}
+
+func Test_extractArchive(t *testing.T) {
+	tests := []struct {
+		in       string
+		filename string
+		bare     bool
+		files    []string
+	}{
+		{in: "test-with-directory.zip", filename: "download", files: []string{"/test/", "/test/foo"}},
+		{in: "test-with-directory.tar.gz", filename: "foo.tgz", files: []string{"/test/", "/test/foo"}},
+		{in: "test-with-directory.tar.bz2", filename: "download", files: []string{"/test/", "/test/foo"}},
+		{in: "test-with-directory.tar.xz", filename: "download", files: []string{"/test/", "/test/foo"}},
+		{in: "test-with-directory.tar", filename: "download", files: []string{"/test/", "/test/foo"}},
+		{in: "kubectl-bare", filename: "kubectl-bare", bare: true, files: []string{"/kubectl-bare"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			f, err := os.Open(filepath.Join(testdataPath(), tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			st, err := f.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dst, err := ioutil.TempDir("", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dst)
+
+			if err := extractArchive(tt.filename, tt.bare, dst, f, st.Size(), nil); err != nil {
+				t.Fatalf("extractArchive() error = %v", err)
+			}
+			if got := collectFiles(t, dst); !reflect.DeepEqual(got, tt.files) {
+				t.Errorf("extractArchive() files = %#v, want %#v", got, tt.files)
+			}
+		})
+	}
+}
+
+func Test_extractBinary_mode(t *testing.T) {
+	dst, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	if err := extractBinary(dst, "kubectl-foo", strings.NewReader("#!/bin/sh"), nil); err != nil {
+		t.Fatal(err)
+	}
+	st, err := os.Stat(filepath.Join(dst, "kubectl-foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode()&0111 == 0 {
+		t.Errorf("binary download is not executable, mode %v", st.Mode())
+	}
+	if err := extractBinary(dst, "..", strings.NewReader(""), nil); err == nil {
+		t.Error("extractBinary() accepted an unsafe file name")
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+var cacheEntryRegexp = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// Cache keeps verified archives on disk, named by their sha256 sum. When the
+// cache grows over MaxSize, the least recently used archives are removed.
+type Cache struct {
+	Dir string
+	// PartialDir is the directory of the interrupted downloads, like
+	// Downloader.PartialDir. They count into MaxSize and are removed with
+	// the archives.
+	PartialDir string
+	// MaxSize is the maximum size of all archives and partial downloads in
+	// bytes. Zero means no limit.
+	MaxSize int64
+}
+
+// CacheEntry is an archive in the cache.
+type CacheEntry struct {
+	Sha256   string
+	Size     int64
+	LastUsed time.Time
+}
+
+func (c Cache) entryPath(sha string) string {
+	return filepath.Join(c.Dir, strings.ToLower(sha))
+}
+
+// signaturePath returns the file of the signature of a cached archive.
+func (c Cache) signaturePath(sha string) string {
+	return c.entryPath(sha) + SignatureSuffix
+}
+
+// contains checks if the archive with the sha256 sum is in the cache.
+func (c Cache) contains(sha string) bool {
+	fi, err := os.Stat(c.entryPath(sha))
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// signature returns the cached signature of an archive. It returns false if
+// the signature is not in the cache.
+func (c Cache) signature(sha string) ([]byte, bool) {
+	data, err := ioutil.ReadFile(c.signaturePath(sha))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.V(1).Infof("Failed to read cached signature of %s, err: %v", sha, err)
+		}
+		return nil, false
+	}
+	sig, err := parseSignature(string(data))
+	if err != nil {
+		glog.V(1).Infof("Ignoring invalid cached signature of %s, err: %v", sha, err)
+		return nil, false
+	}
+	return sig, true
+}
+
+// addSignature stores the signature of a cached archive next to it.
+func (c Cache) addSignature(sha string, sig []byte) error {
+	tmp, err := ioutil.TempFile(c.Dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("could not create file in cache dir, err: %v", err)
+	}
+	_, err = tmp.WriteString(base64.StdEncoding.EncodeToString(sig))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.signaturePath(sha))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write signature to cache, err: %v", err)
+	}
+	return nil
+}
+
+// open returns the cached archive with the sha256 sum. It returns false if
+// the archive is not in the cache. The archive is checked with v, or only
+// against the sha256 sum if v is nil, and removed if it fails. If
+// startVerify is set, it is called to report the progress of verifying the
+// archive.
+func (c Cache) open(sha string, v verifier, startVerify func(size int64) *phaseReporter) (*os.File, int64, bool) {
+	path := c.entryPath(sha)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, false
+	} else if err != nil {
+		glog.V(1).Infof("Failed to open cached archive %q, err: %v", path, err)
+		return nil, 0, false
+	}
+	if v == nil {
+		if v, err = newSha256Verifier(sha); err != nil {
+			f.Close()
+			return nil, 0, false
+		}
+	}
+	progress := &phaseReporter{total: -1}
+	if startVerify != nil {
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, false
+		}
+		progress = startVerify(fi.Size())
+	}
+	size, err := io.Copy(io.MultiWriter(v, progress), f)
+	if err == nil {
+		err = v.Verify()
+	}
+	if err != nil {
+		glog.Warningf("Removing archive %q that fails the verification from the cache, err: %v", path, err)
+		f.Close()
+		os.Remove(path)
+		os.Remove(c.signaturePath(sha))
+		return nil, 0, false
+	}
+	progress.finish()
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		glog.V(1).Infof("Failed to update the last use of %q, err: %v", path, err)
+	}
+	return f, size, true
+}
+
+// add copies a verified archive into the cache and evicts old archives if the
+// cache is too large.
+func (c Cache) add(sha string, r io.ReaderAt, size int64) error {
+	if c.MaxSize > 0 && size > c.MaxSize {
+		glog.V(2).Infof("Not caching archive of %d bytes, it is larger than the cache", size)
+		return nil
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("could not create cache dir, err: %v", err)
+	}
+	tmp, err := ioutil.TempFile(c.Dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("could not create file in cache dir, err: %v", err)
+	}
+	_, err = io.Copy(tmp, io.NewSectionReader(r, 0, size))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.entryPath(sha))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write archive to cache, err: %v", err)
+	}
+	glog.V(2).Infof("Added archive %s to the cache", sha)
+	return c.evict()
+}
+
+// cacheFile is an archive or a partial download in the cache.
+type cacheFile struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// evict removes the least recently used archives and partial downloads until
+// the cache fits into MaxSize. Partial downloads that are in use are kept.
+func (c Cache) evict() error {
+	if c.MaxSize <= 0 {
+		return nil
+	}
+	entries, err := c.List()
+	if err != nil {
+		return err
+	}
+	files, err := c.partialFiles()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		files = append(files, cacheFile{path: c.entryPath(e.Sha256), size: e.Size, lastUsed: e.LastUsed})
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].lastUsed.Before(files[j].lastUsed) })
+	for _, f := range files {
+		if total <= c.MaxSize {
+			break
+		}
+		if isPartialLocked(f.path) {
+			continue
+		}
+		glog.V(2).Infof("Evicting %q from the cache", f.path)
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove cached archive, err: %v", err)
+		}
+		if filepath.Ext(f.path) == ".partial" {
+			// A stale lock of the partial download is not needed anymore.
+			os.Remove(partialLockPath(f.path))
+		} else {
+			os.Remove(f.path + SignatureSuffix)
+		}
+		total -= f.size
+	}
+	return nil
+}
+
+// partialFiles returns the partial downloads in PartialDir.
+func (c Cache) partialFiles() ([]cacheFile, error) {
+	if c.PartialDir == "" {
+		return nil, nil
+	}
+	infos, err := ioutil.ReadDir(c.PartialDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read partial download dir, err: %v", err)
+	}
+	var files []cacheFile
+	for _, fi := range infos {
+		if !fi.Mode().IsRegular() || filepath.Ext(fi.Name()) != ".partial" {
+			continue
+		}
+		files = append(files, cacheFile{path: filepath.Join(c.PartialDir, fi.Name()), size: fi.Size(), lastUsed: fi.ModTime()})
+	}
+	return files, nil
+}
+
+// PartialSize returns the size of the partial downloads in bytes.
+func (c Cache) PartialSize() (int64, error) {
+	files, err := c.partialFiles()
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+	return size, err
+}
+
+// List returns the archives in the cache, sorted by their sha256 sum.
+func (c Cache) List() ([]CacheEntry, error) {
+	files, err := ioutil.ReadDir(c.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read cache dir, err: %v", err)
+	}
+	var entries []CacheEntry
+	for _, f := range files {
+		if !f.Mode().IsRegular() || !cacheEntryRegexp.MatchString(f.Name()) {
+			continue
+		}
+		entries = append(entries, CacheEntry{Sha256: f.Name(), Size: f.Size(), LastUsed: f.ModTime()})
+	}
+	return entries, nil
+}
+
+// Clean removes all archives and partial downloads that are not in use from
+// the cache.
+func (c Cache) Clean() error {
+	if err := os.RemoveAll(c.Dir); err != nil {
+		return fmt.Errorf("could not remove cache dir, err: %v", err)
+	}
+	files, err := c.partialFiles()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if isPartialLocked(f.path) {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove partial download, err: %v", err)
+		}
+		os.Remove(partialLockPath(f.path))
+	}
+	return nil
+}
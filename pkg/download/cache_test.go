@@ -0,0 +1,185 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func newTestCache(t *testing.T, maxSize int64) (Cache, func()) {
+	dir, err := ioutil.TempDir("", "cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Cache{Dir: filepath.Join(dir, "downloads"), MaxSize: maxSize}, func() { os.RemoveAll(dir) }
+}
+
+func TestCache_addAndOpen(t *testing.T) {
+	c, cleanup := newTestCache(t, 0)
+	defer cleanup()
+
+	content := "archive"
+	if _, _, ok := c.open(sha256Hex(content), nil, nil); ok {
+		t.Fatal("open() found archive in empty cache")
+	}
+	if err := c.add(sha256Hex(content), strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+	f, size, ok := c.open(sha256Hex(content), nil, nil)
+	if !ok {
+		t.Fatal("open() did not find added archive")
+	}
+	f.Close()
+	if size != int64(len(content)) {
+		t.Errorf("open() size = %d, want %d", size, len(content))
+	}
+
+	// Corrupt archives are removed.
+	if err := ioutil.WriteFile(c.entryPath(sha256Hex(content)), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := c.open(sha256Hex(content), nil, nil); ok {
+		t.Error("open() returned corrupt archive")
+	}
+	if entries, _ := c.List(); len(entries) != 0 {
+		t.Errorf("corrupt archive was not removed, cache has %v", entries)
+	}
+}
+
+func TestCache_evict(t *testing.T) {
+	c, cleanup := newTestCache(t, 10)
+	defer cleanup()
+
+	add := func(content string, lastUsed time.Time) {
+		if err := c.add(sha256Hex(content), strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(c.entryPath(sha256Hex(content)), lastUsed, lastUsed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	add("aaaa", now.Add(-2*time.Hour))
+	add("bbbb", now.Add(-3*time.Hour))
+	// Over the limit, "bbbb" was used least recently.
+	add("cccc", now.Add(-time.Hour))
+	// Larger than the cache, not added.
+	if err := c.add(sha256Hex("dddddddddddd"), strings.NewReader("dddddddddddd"), 12); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, e := range entries {
+		got[e.Sha256] = true
+	}
+	if len(got) != 2 || !got[sha256Hex("aaaa")] || !got[sha256Hex("cccc")] {
+		t.Errorf("List() after eviction = %v, want archives aaaa and cccc", entries)
+	}
+
+	if err := c.Clean(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := c.List(); err != nil || len(entries) != 0 {
+		t.Errorf("List() after Clean() = %v, %v", entries, err)
+	}
+}
+
+func TestCache_evictPartial(t *testing.T) {
+	c, cleanup := newTestCache(t, 10)
+	defer cleanup()
+	c.PartialDir = filepath.Join(filepath.Dir(c.Dir), "partial")
+	if err := os.MkdirAll(c.PartialDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	writePartial := func(name, content string, lastUsed time.Time) string {
+		path := filepath.Join(c.PartialDir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, lastUsed, lastUsed); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	old := writePartial("old.partial", "pppp", now.Add(-3*time.Hour))
+	inUse := writePartial("in-use.partial", "qqqq", now.Add(-2*time.Hour))
+	if !lockPartial(inUse) {
+		t.Fatal("lockPartial() of an unused file = false")
+	}
+	defer unlockPartial(inUse)
+	if lockPartial(inUse) {
+		t.Error("lockPartial() of a locked file = true")
+	}
+	// The lock of a crashed download is taken over.
+	stale := writePartial("stale.partial", "", now.Add(-time.Hour))
+	if err := ioutil.WriteFile(partialLockPath(stale), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	staleTime := now.Add(-partialLockTimeout - time.Minute)
+	if err := os.Chtimes(partialLockPath(stale), staleTime, staleTime); err != nil {
+		t.Fatal(err)
+	}
+	if !lockPartial(stale) {
+		t.Error("lockPartial() with a stale lock = false")
+	}
+	unlockPartial(stale)
+	if _, err := os.Stat(partialLockPath(stale)); !os.IsNotExist(err) {
+		t.Errorf("unlockPartial() kept the lock file, err: %v", err)
+	}
+
+	// Over the limit, the old partial download is removed, the one in use
+	// is kept.
+	if err := c.add(sha256Hex("aaaa"), strings.NewReader("aaaa"), 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("old partial download was not evicted, err: %v", err)
+	}
+	if _, err := os.Stat(inUse); err != nil {
+		t.Errorf("partial download in use was evicted, err: %v", err)
+	}
+	if size, err := c.PartialSize(); err != nil || size != 4 {
+		t.Errorf("PartialSize() = %d, %v, want 4", size, err)
+	}
+
+	writePartial("other.partial", "rr", now)
+	if err := c.Clean(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := c.partialFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].path != inUse {
+		t.Errorf("partial downloads after Clean() = %v, want only the one in use", files)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// isInside checks if the path is the base directory or inside of it.
+func isInside(base, p string) bool {
+	rel, err := filepath.Rel(base, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isAbsEntry checks if a slash separated archive path is absolute on any
+// platform.
+func isAbsEntry(name string) bool {
+	return path.IsAbs(name) || strings.HasPrefix(name, `\`) || filepath.IsAbs(filepath.FromSlash(name)) || filepath.VolumeName(filepath.FromSlash(name)) != ""
+}
+
+// entryPath returns the path of an archive entry in the target directory. It
+// fails if the entry is outside of the directory, or below a symbolic link
+// that was extracted before.
+func entryPath(targetDir, name string) (string, error) {
+	if name == "" || isAbsEntry(name) {
+		return "", fmt.Errorf("archive entry %q is not a relative path", name)
+	}
+	p := filepath.Join(targetDir, filepath.FromSlash(name))
+	if !isInside(targetDir, p) {
+		return "", fmt.Errorf("archive entry %q is outside of the extraction directory", name)
+	}
+	rel, err := filepath.Rel(targetDir, p)
+	if err != nil {
+		return "", err
+	}
+	dir := targetDir
+	elems := strings.Split(rel, string(filepath.Separator))
+	for _, e := range elems[:len(elems)-1] {
+		dir = filepath.Join(dir, e)
+		fi, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("archive entry %q is below a symbolic link", name)
+		}
+	}
+	return p, nil
+}
+
+// checkSymlinkTarget checks that a symbolic link at linkPath that points to
+// target stays inside the target directory. Only leading ".." elements are
+// allowed, so the target can't leave the directory through another link.
+func checkSymlinkTarget(targetDir, linkPath, target string) error {
+	if target == "" || isAbsEntry(target) {
+		return fmt.Errorf("symbolic link target %q is not a relative path", target)
+	}
+	leading := true
+	for _, e := range strings.Split(strings.Replace(target, `\`, "/", -1), "/") {
+		if e != ".." {
+			leading = false
+		} else if !leading {
+			return fmt.Errorf("symbolic link target %q has \"..\" after other path elements", target)
+		}
+	}
+	if !isInside(targetDir, filepath.Join(filepath.Dir(linkPath), filepath.FromSlash(target))) {
+		return fmt.Errorf("symbolic link target %q is outside of the extraction directory", target)
+	}
+	return nil
+}
+
+// createSymlink creates a symbolic link for an archive entry.
+func createSymlink(targetDir, linkPath, target string) error {
+	if err := checkSymlinkTarget(targetDir, linkPath, target); err != nil {
+		return err
+	}
+	if err := prepareEntry(linkPath); err != nil {
+		return err
+	}
+	return os.Symlink(filepath.FromSlash(target), linkPath)
+}
+
+// createHardlink creates a hard link for an archive entry to a regular file
+// that was extracted before.
+func createHardlink(targetDir, linkPath, targetName string) error {
+	target, err := entryPath(targetDir, targetName)
+	if err != nil {
+		return fmt.Errorf("invalid hard link target, err: %v", err)
+	}
+	fi, err := os.Lstat(target)
+	if err != nil {
+		return fmt.Errorf("hard link target %q was not extracted before the link, err: %v", targetName, err)
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("hard link target %q is not a regular file", targetName)
+	}
+	if err := prepareEntry(linkPath); err != nil {
+		return err
+	}
+	return os.Link(target, linkPath)
+}
+
+// writeEntryFile writes a regular file for an archive entry. Only the
+// permission bits of the mode are kept, setuid, setgid and sticky bits are
+// dropped.
+func writeEntryFile(p string, r io.Reader, mode os.FileMode) error {
+	if err := prepareEntry(p); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create file %q: %+v", p, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write file %q: %+v", p, err)
+	}
+	return f.Close()
+}
+
+// createEntryDir creates a directory for an archive entry. The owner can
+// always write to it, so the following entries can be extracted.
+func createEntryDir(p string, mode os.FileMode) error {
+	if err := os.MkdirAll(p, mode.Perm()|0700); err != nil {
+		return fmt.Errorf("failed to create directory %q: %+v", p, err)
+	}
+	return nil
+}
+
+// prepareEntry creates the parent directories of an entry and removes a link
+// that an earlier entry created at the same path.
+func prepareEntry(p string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory of %q: %+v", p, err)
+	}
+	if fi, err := os.Lstat(p); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("failed to replace symbolic link %q: %+v", p, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type testEntry struct {
+	name string
+	typ  byte // a tar type flag
+	link string
+	body string
+	mode int64
+}
+
+func testTAR(t testing.TB, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, e := range entries {
+		mode := e.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typ, Linkname: e.link, Mode: mode}
+		if e.typ == tar.TypeReg {
+			hdr.Size = int64(len(e.body))
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testZIP(t testing.TB, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Store}
+		body := e.body
+		switch e.typ {
+		case tar.TypeDir:
+			hdr.SetMode(os.ModeDir | 0755)
+		case tar.TypeSymlink:
+			hdr.SetMode(os.ModeSymlink | 0777)
+			body = e.link
+		default:
+			hdr.SetMode(os.FileMode(e.mode) | 0644)
+		}
+		f, err := w.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// extractConfined extracts into a target directory inside a new parent
+// directory and fails the test if anything was written outside of the target.
+func extractConfined(t testing.TB, extract func(targetDir string) error) (string, func(), error) {
+	parent, err := ioutil.TempDir("", "krew-confine-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetDir := filepath.Join(parent, "target")
+	if err := os.Mkdir(targetDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	extractErr := extract(targetDir)
+	if names, err := filepath.Glob(filepath.Join(parent, "*")); err != nil {
+		t.Fatal(err)
+	} else if len(names) != 1 {
+		t.Errorf("extraction wrote outside of the target directory: %v", names)
+	}
+	return targetDir, func() { os.RemoveAll(parent) }, extractErr
+}
+
+func Test_entryPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "foo", want: "/tmp/target/foo"},
+		{name: "./a/b/", want: "/tmp/target/a/b"},
+		{name: "a/../b", want: "/tmp/target/b"},
+		{name: "../foo", wantErr: true},
+		{name: "a/../../foo", wantErr: true},
+		{name: "/etc/passwd", wantErr: true},
+		{name: `\etc\passwd`, wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := entryPath("/tmp/target", tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("entryPath(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("entryPath(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+var confinementTests = []struct {
+	name    string
+	entries []testEntry
+	files   []string
+	wantErr bool
+}{
+	{
+		name:    "parent directories are created",
+		entries: []testEntry{{name: "a/b/foo", typ: tar.TypeReg, body: "foo"}},
+		files:   []string{"/a/", "/a/b/", "/a/b/foo"},
+	},
+	{
+		name:    "traversal with dot dot",
+		entries: []testEntry{{name: "../evil", typ: tar.TypeReg, body: "evil"}},
+		wantErr: true,
+	},
+	{
+		name:    "absolute path",
+		entries: []testEntry{{name: "/tmp/evil", typ: tar.TypeReg, body: "evil"}},
+		wantErr: true,
+	},
+	{
+		name: "symlink inside of the archive",
+		entries: []testEntry{
+			{name: "bin/foo", typ: tar.TypeReg, body: "foo"},
+			{name: "kubectl-foo", typ: tar.TypeSymlink, link: "bin/foo"},
+			{name: "bin/foo-link", typ: tar.TypeSymlink, link: "../bin/foo"},
+		},
+		files: []string{"/bin/", "/bin/foo", "/bin/foo-link", "/kubectl-foo"},
+	},
+	{
+		name:    "symlink to the parent",
+		entries: []testEntry{{name: "up", typ: tar.TypeSymlink, link: ".."}},
+		wantErr: true,
+	},
+	{
+		name:    "absolute symlink",
+		entries: []testEntry{{name: "passwd", typ: tar.TypeSymlink, link: "/etc/passwd"}},
+		wantErr: true,
+	},
+	{
+		name: "dot dot after a symlink",
+		entries: []testEntry{
+			{name: "here", typ: tar.TypeSymlink, link: "."},
+			{name: "up", typ: tar.TypeSymlink, link: "here/.."},
+		},
+		wantErr: true,
+	},
+	{
+		name: "write through a symlink",
+		entries: []testEntry{
+			{name: "dir", typ: tar.TypeDir},
+			{name: "link", typ: tar.TypeSymlink, link: "dir"},
+			{name: "link/foo", typ: tar.TypeReg, body: "foo"},
+		},
+		wantErr: true,
+	},
+	{
+		name: "file replaces a symlink",
+		entries: []testEntry{
+			{name: "foo", typ: tar.TypeReg, body: "foo"},
+			{name: "link", typ: tar.TypeSymlink, link: "foo"},
+			{name: "link", typ: tar.TypeReg, body: "bar"},
+		},
+		files: []string{"/foo", "/link"},
+	},
+}
+
+func Test_extractTAR_confinement(t *testing.T) {
+	tests := append(confinementTests, []struct {
+		name    string
+		entries []testEntry
+		files   []string
+		wantErr bool
+	}{
+		{
+			name: "hardlink inside of the archive",
+			entries: []testEntry{
+				{name: "foo", typ: tar.TypeReg, body: "foo"},
+				{name: "bar", typ: tar.TypeLink, link: "foo"},
+			},
+			files: []string{"/bar", "/foo"},
+		},
+		{
+			name:    "hardlink outside of the archive",
+			entries: []testEntry{{name: "passwd", typ: tar.TypeLink, link: "../../etc/passwd"}},
+			wantErr: true,
+		},
+		{
+			name: "hardlink to a symlink",
+			entries: []testEntry{
+				{name: "foo", typ: tar.TypeReg, body: "foo"},
+				{name: "link", typ: tar.TypeSymlink, link: "foo"},
+				{name: "bar", typ: tar.TypeLink, link: "link"},
+			},
+			wantErr: true,
+		},
+	}...)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := testTAR(t, tt.entries)
+			targetDir, cleanup, err := extractConfined(t, func(dir string) error {
+				return extractTAR(dir, bytes.NewReader(in), nil)
+			})
+			defer cleanup()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractTAR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if files := collectFiles(t, targetDir); !reflect.DeepEqual(files, tt.files) {
+					t.Errorf("extractTAR() files = %v, want %v", files, tt.files)
+				}
+			}
+		})
+	}
+}
+
+func Test_extractZIP_confinement(t *testing.T) {
+	for _, tt := range confinementTests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := testZIP(t, tt.entries)
+			targetDir, cleanup, err := extractConfined(t, func(dir string) error {
+				return extractZIP(dir, bytes.NewReader(in), int64(len(in)), nil)
+			})
+			defer cleanup()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractZIP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if files := collectFiles(t, targetDir); !reflect.DeepEqual(files, tt.files) {
+					t.Errorf("extractZIP() files = %v, want %v", files, tt.files)
+				}
+			}
+		})
+	}
+}
+
+func Test_extractTAR_stripsSpecialBits(t *testing.T) {
+	in := testTAR(t, []testEntry{
+		{name: "dir", typ: tar.TypeDir, mode: 01555},
+		{name: "dir/foo", typ: tar.TypeReg, body: "foo", mode: 06755},
+	})
+	targetDir, cleanup, err := extractConfined(t, func(dir string) error {
+		return extractTAR(dir, bytes.NewReader(in), nil)
+	})
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]os.FileMode{"dir": os.ModeDir | 0755, "dir/foo": 0755} {
+		fi, err := os.Stat(filepath.Join(targetDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode() != want {
+			t.Errorf("mode of %q = %v, want %v", name, fi.Mode(), want)
+		}
+	}
+}
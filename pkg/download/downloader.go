@@ -17,41 +17,255 @@ package download
 import (
 	"archive/tar"
 	"archive/zip"
-	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/ulikunitz/xz"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
-// download gets a file from the internet in memory and writes it content
-// to a verifier.
-func download(url string, verifier verifier, fetcher Fetcher) (io.ReaderAt, int64, error) {
+// sizer is implemented by download streams that know their size.
+type sizer interface {
+	Size() int64
+}
+
+// rangeAccepter is implemented by download streams from servers that can
+// resume downloads.
+type rangeAccepter interface {
+	AcceptsRanges() bool
+}
+
+// resumeError is returned when a resumed download fails the verification.
+// The partial file may be from another file at the same URI, so it was
+// removed.
+type resumeError struct {
+	err error
+}
+
+func (e *resumeError) Error() string {
+	return fmt.Sprintf("resumed download is invalid, err: %v", e.err)
+}
+
+// download streams a file from the internet into a temporary file and writes
+// its content to a verifier. The caller has to remove the returned file.
+// The mirror is set in the last download progress event.
+//
+// If partial is set, the download is written to that file. A failed download
+// is kept there if the server can resume it, and the next download continues
+// where it stopped.
+func (d Downloader) download(ctx context.Context, label, url, mirror, partial string, verifier verifier) (*os.File, int64, error) {
+	if partial != "" && !lockPartial(partial) {
+		glog.V(1).Infof("Partial download %q is in use, downloading %q to a temporary file", partial, url)
+		partial = ""
+	}
+	f, offset, err := openDownloadFile(partial)
+	if err != nil {
+		unlockPartial(partial)
+		return nil, 0, fmt.Errorf("could not create a file for the download, err: %v", err)
+	}
 	glog.V(2).Infof("Fetching %q", url)
-	body, err := fetcher.Get(url)
+	body, start, err := d.fetch(ctx, url, offset)
 	if err != nil {
+		closeDownloadFile(f, offset > 0)
 		return nil, 0, fmt.Errorf("could not download %q, err %v: ", url, err)
 	}
 	defer body.Close()
+	if start > 0 {
+		glog.V(1).Infof("Resuming download of %q at %d bytes", url, start)
+	}
+	if err := resumeAt(f, start, verifier); err != nil {
+		removeFile(f)
+		return nil, 0, fmt.Errorf("could not resume download, err: %v", err)
+	}
+	total := int64(-1)
+	if s, ok := body.(sizer); ok && s.Size() >= 0 {
+		total = start + s.Size()
+	}
+	if max := d.Limits.MaxDownloadSize; max > 0 && total > max {
+		removeFile(f)
+		return nil, 0, &LimitError{Limit: LimitDownloadSize, Max: max}
+	}
 
-	glog.V(3).Infof("Reading download data into memory")
-	data, err := ioutil.ReadAll(io.TeeReader(body, verifier))
-	if err != nil {
+	glog.V(3).Infof("Writing download data to %q", f.Name())
+	progress := d.startPhase(label, PhaseDownload, total)
+	if start > 0 {
+		progress.add(start)
+	}
+	n, err := io.Copy(f, io.TeeReader(io.TeeReader(d.Limits.limitDownload(body, start), verifier), progress))
+	size := start + n
+	if lerr, ok := err.(*LimitError); ok {
+		removeFile(f)
+		return nil, 0, lerr
+	} else if err != nil {
+		ra, ok := body.(rangeAccepter)
+		closeDownloadFile(f, partial != "" && size > 0 && (start > 0 || ok && ra.AcceptsRanges()))
 		return nil, 0, fmt.Errorf("could not read download content, err %v: ", err)
 	}
-	glog.V(2).Infof("Read %d bytes of download data into memory", len(data))
+	progress.mirror = mirror
+	progress.finish()
+	glog.V(2).Infof("Wrote %d bytes of download data to %q", size, f.Name())
+
+	// The content was hashed while it was downloaded.
+	progress = d.startPhase(label, PhaseVerify, size)
+	if err := verifier.Verify(); err != nil {
+		removeFile(f)
+		if start > 0 {
+			return nil, 0, &resumeError{err: err}
+		}
+		return nil, 0, err
+	}
+	progress.finish()
+	return f, size, nil
+}
+
+// fetch gets the download from the offset on if the fetcher can resume
+// downloads. It returns where the body starts.
+func (d Downloader) fetch(ctx context.Context, url string, offset int64) (io.ReadCloser, int64, error) {
+	if rf, ok := d.Fetcher.(RangeFetcher); ok && offset > 0 {
+		return rf.GetRange(ctx, url, offset)
+	}
+	body, err := d.Fetcher.Get(ctx, url)
+	return body, 0, err
+}
+
+// openDownloadFile opens the partial file of a download and returns its
+// size. Without a partial file a new temporary file is created.
+func openDownloadFile(partial string) (*os.File, int64, error) {
+	if partial == "" {
+		f, err := ioutil.TempFile("", "krew-download-")
+		return f, 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(partial), 0755); err != nil {
+		return nil, 0, err
+	}
+	f, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
+// resumeAt truncates the download file to the start of the body and hashes
+// the data before it.
+func resumeAt(f *os.File, start int64, verifier verifier) error {
+	if err := f.Truncate(start); err != nil {
+		return err
+	}
+	if _, err := io.Copy(verifier, io.NewSectionReader(f, 0, start)); err != nil {
+		return err
+	}
+	_, err := f.Seek(start, io.SeekStart)
+	return err
+}
+
+// closeDownloadFile closes a failed download and keeps it if it can be
+// resumed.
+func closeDownloadFile(f *os.File, keep bool) {
+	if !keep {
+		removeFile(f)
+		return
+	}
+	glog.V(1).Infof("Keeping partial download %q", f.Name())
+	f.Close()
+	unlockPartial(f.Name())
+}
+
+// partialPath returns the file that keeps an unfinished download. Only
+// downloads with a checksum are resumed, because a changed file at the URI
+// can't be detected otherwise.
+func (d Downloader) partialPath(uri string, v Verification) string {
+	if d.PartialDir == "" || (v.Sha256 == "" && v.Sha512 == "") {
+		return ""
+	}
+	key := sha256.Sum256([]byte(uri + "\x00" + strings.ToLower(v.Sha256) + "\x00" + strings.ToLower(v.Sha512)))
+	return filepath.Join(d.PartialDir, hex.EncodeToString(key[:16])+".partial")
+}
+
+// partialLockTimeout is the age after which the lock of a partial download
+// is stale. The lock of a crashed krew is not removed and would keep the
+// partial download from being resumed or evicted forever.
+const partialLockTimeout = 24 * time.Hour
+
+// partialLockPath returns the lock file of a partial download.
+func partialLockPath(path string) string {
+	return path + ".lock"
+}
+
+// lockPartial creates the lock file next to the partial file, so that no
+// other download of this or another krew process writes to it. It returns
+// false if another download holds the lock.
+func lockPartial(path string) bool {
+	lock := partialLockPath(path)
+	if err := os.MkdirAll(filepath.Dir(lock), 0755); err != nil {
+		glog.V(1).Infof("Could not create the partial download dir, err: %v", err)
+		return false
+	}
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return true
+		}
+		if !os.IsExist(err) {
+			glog.V(1).Infof("Could not lock partial download %q, err: %v", path, err)
+			return false
+		}
+		if isPartialLocked(path) {
+			return false
+		}
+		glog.V(2).Infof("Removing stale lock %q", lock)
+		if err := os.Remove(lock); err != nil && !os.IsNotExist(err) {
+			return false
+		}
+	}
+	return false
+}
+
+// unlockPartial removes the lock of a partial file. Files without a lock,
+// like temporary downloads, are ignored.
+func unlockPartial(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(partialLockPath(path)); err != nil && !os.IsNotExist(err) {
+		glog.V(1).Infof("Failed to unlock partial download %q, err: %v", path, err)
+	}
+}
+
+// isPartialLocked checks if a download of any krew process writes the
+// partial file.
+func isPartialLocked(path string) bool {
+	fi, err := os.Stat(partialLockPath(path))
+	return err == nil && time.Since(fi.ModTime()) < partialLockTimeout
+}
 
-	return bytes.NewReader(data), int64(len(data)), verifier.Verify()
+// removeFile closes and deletes a downloaded file.
+func removeFile(f *os.File) {
+	f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		glog.V(1).Infof("Failed to remove download file %q, err: %v", f.Name(), err)
+	}
+	unlockPartial(f.Name())
 }
 
 // extractZIP extracts a zip file into the target directory.
-func extractZIP(targetDir string, read io.ReaderAt, size int64) error {
+func extractZIP(targetDir string, read io.ReaderAt, size int64, limiter *extractLimiter) error {
 	glog.V(4).Infof("Extracting download zip to %q", targetDir)
 	zipReader, err := zip.NewReader(read, size)
 	if err != nil {
@@ -59,9 +273,18 @@ func extractZIP(targetDir string, read io.ReaderAt, size int64) error {
 	}
 
 	for _, f := range zipReader.File {
-		path := filepath.Join(targetDir, filepath.FromSlash(f.Name))
-		if f.FileInfo().IsDir() {
-			os.MkdirAll(path, f.Mode())
+		if err := limiter.entry(); err != nil {
+			return err
+		}
+		path, err := entryPath(targetDir, f.Name)
+		if err != nil {
+			return err
+		}
+		mode := f.Mode()
+		if mode.IsDir() {
+			if err := createEntryDir(path, mode); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -69,36 +292,41 @@ func extractZIP(targetDir string, read io.ReaderAt, size int64) error {
 		if err != nil {
 			return fmt.Errorf("could not open inflating zip file, err: %v", err)
 		}
-
-		dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode())
-		if err != nil {
-			return fmt.Errorf("can't create file in zip destination dir, err: %v", err)
-		}
-
-		if _, err := io.Copy(dst, src); err != nil {
-			return fmt.Errorf("can't copy content to zip destination file, err: %v", err)
+		if mode&os.ModeSymlink != 0 {
+			var target []byte
+			if target, err = ioutil.ReadAll(io.LimitReader(src, 4096)); err == nil {
+				err = createSymlink(targetDir, path, string(target))
+			}
+		} else if mode.IsRegular() {
+			err = writeEntryFile(path, limiter.reader(src), mode)
+		} else {
+			err = fmt.Errorf("unable to handle file type %v for %q in zip", mode.Type(), f.Name)
 		}
-
 		// Cleanup the open fd. Don't use defer in case of many files.
-		// Don't be blocking
 		src.Close()
-		dst.Close()
+		if err != nil {
+			return fmt.Errorf("can't extract %q from zip, err: %v", f.Name, err)
+		}
 	}
 
 	return nil
 }
 
 // extractTARGZ extracts a gzipped tar file into the target directory.
-func extractTARGZ(targetDir string, in io.Reader) error {
-	glog.V(4).Infof("tar: extracting to %q", targetDir)
-
+func extractTARGZ(targetDir string, in io.Reader, limiter *extractLimiter) error {
 	gzr, err := gzip.NewReader(in)
 	if err != nil {
 		return fmt.Errorf("failed to create gzip reader: %+v", err)
 	}
 	defer gzr.Close()
+	return extractTAR(targetDir, gzr, limiter)
+}
 
-	tr := tar.NewReader(gzr)
+// extractTAR extracts a tar file into the target directory.
+func extractTAR(targetDir string, in io.Reader, limiter *extractLimiter) error {
+	glog.V(4).Infof("tar: extracting to %q", targetDir)
+
+	tr := tar.NewReader(in)
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -113,64 +341,289 @@ func extractTARGZ(targetDir string, in io.Reader) error {
 			glog.V(4).Infof("tar: skipping pax_global_header file")
 			continue
 		}
+		if err := limiter.entry(); err != nil {
+			return err
+		}
 
-		path := filepath.Join(targetDir, filepath.FromSlash(hdr.Name))
+		path, err := entryPath(targetDir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		mode := hdr.FileInfo().Mode()
 		switch hdr.Typeflag {
 		case tar.TypeDir:
-			if err := os.MkdirAll(path, os.FileMode(hdr.Mode)); err != nil {
-				return fmt.Errorf("failed to create directory from tar: %+v", err)
-			}
-		case tar.TypeReg:
-			f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, os.FileMode(hdr.Mode))
-			if err != nil {
-				return fmt.Errorf("failed to create file %q: %+v", path, err)
-			}
-			if _, err := io.Copy(f, tr); err != nil {
-				return fmt.Errorf("failed to copy %q from tar into file: %+v", hdr.Name, err)
-			}
+			err = createEntryDir(path, mode)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeEntryFile(path, limiter.reader(tr), mode)
+		case tar.TypeSymlink:
+			err = createSymlink(targetDir, path, hdr.Linkname)
+		case tar.TypeLink:
+			err = createHardlink(targetDir, path, hdr.Linkname)
 		default:
 			return fmt.Errorf("unable to handle file type %d for %q in tar", hdr.Typeflag, hdr.Name)
 		}
+		if err != nil {
+			return fmt.Errorf("can't extract %q from tar, err: %v", hdr.Name, err)
+		}
 		glog.V(4).Infof("tar: processed %q", hdr.Name)
 	}
 	glog.V(4).Infof("tar extraction to %s complete", targetDir)
 	return nil
 }
 
-// GetWithSha256 downloads a zip, verifies it and extracts it to the dir.
-func GetWithSha256(uri, dir, sha string, fetcher Fetcher) error {
-	name := path.Base(uri)
-	body, size, err := download(uri, newSha256Verifier(sha), fetcher)
-	if err != nil {
+// Downloader gets plugin archives and extracts them.
+type Downloader struct {
+	Fetcher Fetcher
+	// Cache keeps verified archives. Archives are always fetched if it is
+	// nil.
+	Cache *Cache
+	// Progress receives progress events if it is set.
+	Progress ProgressReporter
+	// Label names the archive in progress events. The file name from the
+	// URI is used if it is empty.
+	Label string
+	// Limits bound the size of downloads and extracted archives.
+	Limits Limits
+	// Rewrites add mirrors for download URIs.
+	Rewrites []Rewrite
+	// PartialDir keeps unfinished downloads so they can be resumed.
+	// Downloads are not resumed if it is empty.
+	PartialDir string
+	// BareBinary marks the download as a single executable instead of an
+	// archive. Other downloads that are no known archive are rejected.
+	BareBinary bool
+}
+
+func (d Downloader) label(uri string) string {
+	if d.Label != "" {
+		return d.Label
+	}
+	return archiveName(uri)
+}
+
+// extract extracts the archive within the limits and reports the progress.
+func (d Downloader) extract(label, name, dir string, r io.ReaderAt, size int64) error {
+	progress := d.startPhase(label, PhaseExtract, size)
+	limiter := newExtractLimiter(d.Limits, size)
+	if err := extractArchive(name, d.BareBinary, dir, progressReaderAt{ReaderAt: r, r: progress}, size, limiter); err != nil {
+		if lerr := limiter.exceeded(); lerr != nil {
+			return lerr
+		}
 		return err
 	}
-	return extractArchive(name, dir, body, size)
+	progress.finish()
+	return nil
 }
 
-// GetInsecure downloads a zip and extracts it to the dir.
-func GetInsecure(uri, dir string, fetcher Fetcher) error {
-	name := path.Base(uri)
-	body, size, err := download(uri, newTrueVerifier(), fetcher)
+// Verification lists the checks of a download. A download without checks
+// is not verified.
+type Verification struct {
+	// Sha256 is the hex encoded sha256 sum of the download. Only downloads
+	// with a sha256 sum are cached.
+	Sha256 string
+	// Sha512 is the hex encoded sha512 sum of the download.
+	Sha512 string
+	// PublicKey is an "ed25519:<base64 key>" public key. The download must
+	// have a detached signature that was made with the key, see
+	// SignatureURI.
+	PublicKey string
+}
+
+// verifier returns a verifier for all checks. It downloads the signature if
+// the download has to be signed, and returns it so that it can be cached.
+func (d Downloader) verifier(ctx context.Context, uri string, v Verification) (verifier, []byte, error) {
+	var sig []byte
+	if v.PublicKey != "" {
+		var err error
+		if sig, err = d.fetchSignature(ctx, uri); err != nil {
+			return nil, nil, err
+		}
+	}
+	verifier, err := newVerifier(v, sig)
+	return verifier, sig, err
+}
+
+// newVerifier returns a verifier for all checks. sig is the signature of the
+// download if it has to be signed.
+func newVerifier(v Verification, sig []byte) (verifier, error) {
+	var verifiers multiVerifier
+	if v.Sha256 != "" {
+		sv, err := newSha256Verifier(v.Sha256)
+		if err != nil {
+			return nil, err
+		}
+		verifiers = append(verifiers, sv)
+	}
+	if v.Sha512 != "" {
+		sv, err := newSha512Verifier(v.Sha512)
+		if err != nil {
+			return nil, err
+		}
+		verifiers = append(verifiers, sv)
+	}
+	if v.PublicKey != "" {
+		key, err := ParsePublicKey(v.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		verifiers = append(verifiers, newSignatureVerifier(key, sig))
+	}
+	if len(verifiers) == 0 {
+		return newTrueVerifier(), nil
+	}
+	return verifiers, nil
+}
+
+// cacheVerifier returns the verifier for an archive in the cache, or nil if
+// the archive is not cached. The signature is read from the cache. If an
+// archive was cached without its signature, the signature is downloaded and
+// returned so that it can be added to the cache.
+func (d Downloader) cacheVerifier(ctx context.Context, cache *Cache, uri string, v Verification) (verifier, []byte, error) {
+	if !cache.contains(v.Sha256) {
+		return nil, nil, nil
+	}
+	if v.PublicKey == "" {
+		verifier, err := newVerifier(v, nil)
+		return verifier, nil, err
+	}
+	if sig, ok := cache.signature(v.Sha256); ok {
+		verifier, err := newVerifier(v, sig)
+		return verifier, nil, err
+	}
+	return d.verifier(ctx, uri, v)
+}
+
+// Get downloads an archive, verifies it and extracts it to the dir. The
+// URIs are mirrors of the same archive, they are tried in order until a
+// download passes the verification. The first URI names the archive.
+// Archives in the cache are not downloaded again.
+func (d Downloader) Get(ctx context.Context, uris []string, dir string, v Verification) error {
+	if len(uris) == 0 {
+		return fmt.Errorf("no URI to download from")
+	}
+	name, label := archiveName(uris[0]), d.label(uris[0])
+	if v.Sha256 != "" {
+		if _, err := newSha256Verifier(v.Sha256); err != nil {
+			return err
+		}
+	}
+	cache := d.Cache
+	if v.Sha256 == "" {
+		cache = nil
+	}
+	if cache != nil {
+		// Cached archives pass the same checks as downloads, including the
+		// sha512 sum and the signature. The signature is cached next to the
+		// archive, so they are checked without the network.
+		if verifier, sig, err := d.cacheVerifier(ctx, cache, uris[0], v); err != nil {
+			glog.V(1).Infof("Not using the cache for %q, err: %v", uris[0], err)
+		} else if verifier != nil {
+			startVerify := func(size int64) *phaseReporter { return d.startPhase(label, PhaseVerify, size) }
+			if f, size, ok := cache.open(v.Sha256, verifier, startVerify); ok {
+				glog.V(1).Infof("Using cached archive for %q", uris[0])
+				defer f.Close()
+				if sig != nil {
+					if err := cache.addSignature(v.Sha256, sig); err != nil {
+						glog.Warningf("Failed to cache the signature of %q, err: %v", uris[0], err)
+					}
+				}
+				return d.extract(label, name, dir, f, size)
+			}
+		}
+	}
+
+	var errs []error
+	for _, uri := range d.candidates(uris) {
+		mirror := ""
+		if uri != uris[0] {
+			mirror = uri
+		}
+		f, size, sig, err := d.fetchVerified(ctx, label, uri, mirror, v)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			glog.Warningf("Failed to get %q, err: %v", uri, err)
+			errs = append(errs, err)
+			continue
+		}
+		defer removeFile(f)
+		if cache != nil {
+			if err := cache.add(v.Sha256, f, size); err != nil {
+				glog.Warningf("Failed to cache %q, err: %v", uri, err)
+			} else if sig != nil {
+				if err := cache.addSignature(v.Sha256, sig); err != nil {
+					glog.Warningf("Failed to cache the signature of %q, err: %v", uri, err)
+				}
+			}
+		}
+		return d.extract(label, name, dir, f, size)
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// fetchVerified downloads and verifies an archive from one URI. The mirror is
+// reported when the download finished. If a resumed download is invalid, it
+// is downloaded again from the start. It returns the signature of a signed
+// archive.
+func (d Downloader) fetchVerified(ctx context.Context, label, uri, mirror string, v Verification) (*os.File, int64, []byte, error) {
+	partial := d.partialPath(uri, v)
+	verifier, sig, err := d.verifier(ctx, uri, v)
 	if err != nil {
-		return err
+		return nil, 0, nil, err
+	}
+	f, size, err := d.download(ctx, label, uri, mirror, partial, verifier)
+	if rerr, ok := err.(*resumeError); ok {
+		glog.Warningf("Restarting download of %q, err: %v", uri, rerr)
+		if verifier, err = newVerifier(v, sig); err != nil {
+			return nil, 0, nil, err
+		}
+		// The partial file was removed, so this download starts from the
+		// beginning.
+		f, size, err = d.download(ctx, label, uri, mirror, partial, verifier)
 	}
-	return extractArchive(name, dir, body, size)
+	return f, size, sig, err
 }
 
-func extractArchive(filename, dst string, r io.ReaderAt, size int64) error {
-	// TODO(ahmetb) This package is not architected well, this method should not
-	// be receiving this many args. Primary problem is at GetInsecure and
-	// GetWithSha256 methods that embed extraction in them, which is orthogonal.
+// GetWithSha256 downloads a zip, verifies it and extracts it to the dir.
+// Archives in the cache are not downloaded again.
+func (d Downloader) GetWithSha256(ctx context.Context, uri, dir, sha string) error {
+	return d.Get(ctx, []string{uri}, dir, Verification{Sha256: sha})
+}
 
-	// TODO(ahmetb) write tests with this by mocking extractZIP function into a
-	// zipExtractor variable and check its execution.
+// GetInsecure downloads a zip and extracts it to the dir.
+func (d Downloader) GetInsecure(ctx context.Context, uri, dir string) error {
+	return d.Get(ctx, []string{uri}, dir, Verification{})
+}
 
-	if strings.HasSuffix(filename, ".zip") {
-		glog.V(4).Infof("detected .zip file")
-		return extractZIP(dst, r, size)
-	} else if strings.HasSuffix(filename, ".tar.gz") {
-		glog.V(4).Infof("detected .tar.gz file")
-		return extractTARGZ(dst, io.NewSectionReader(r, 0, size))
+func extractArchive(filename string, bare bool, dst string, r io.ReaderAt, size int64, limiter *extractLimiter) error {
+	format, err := detectArchiveFormat(filename, bare, r)
+	if err != nil {
+		return err
+	}
+	glog.V(4).Infof("detected %s file", format)
+	in := io.NewSectionReader(r, 0, size)
+	switch format {
+	case formatZIP:
+		return extractZIP(dst, r, size, limiter)
+	case formatGzip:
+		return extractTARGZ(dst, in, limiter)
+	case formatBzip2:
+		return extractTAR(dst, bzip2.NewReader(in), limiter)
+	case formatXZ:
+		xzr, err := xz.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("failed to create xz reader: %+v", err)
+		}
+		return extractTAR(dst, xzr, limiter)
+	case formatTAR:
+		return extractTAR(dst, in, limiter)
+	case formatBinary:
+		return extractBinary(dst, filename, in, limiter)
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
 	}
-	return fmt.Errorf("cannot infer a supported archive type from filename in the url (%q)", filename)
 }
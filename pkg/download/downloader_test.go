@@ -15,6 +15,11 @@
 package download
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -62,7 +67,7 @@ func Test_extractZIP(t *testing.T) {
 		}
 		defer zipReader.Close()
 		stat, _ := zipReader.Stat()
-		if err := extractZIP(zipDst, zipReader, stat.Size()); err != nil {
+		if err := extractZIP(zipDst, zipReader, stat.Size(), nil); err != nil {
 			t.Fatalf("extractZIP(%s) error = %v", tt.in, err)
 		}
 
@@ -104,7 +109,7 @@ func Test_extractTARGZ(t *testing.T) {
 		}
 		defer tf.Close()
 
-		if err := extractTARGZ(tarDst, tf); err != nil {
+		if err := extractTARGZ(tarDst, tf, nil); err != nil {
 			t.Fatalf("failed to extract %q. error=%v", tt.in, err)
 		}
 
@@ -134,3 +139,73 @@ func collectFiles(t *testing.T, scanPath string) []string {
 	}
 	return outFiles
 }
+
+func Test_download(t *testing.T) {
+	content := "some archive content"
+	wrongSum := "5e1f4e4c61bd31e1a9d1ba0ba6ab45a1b9c1d2f4e8e6b1c5cf5b5a4b7e3b8b0a"
+	v, err := newSha256Verifier(wrongSum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Downloader{Fetcher: FakeFetcher{ioutil.NopCloser(strings.NewReader(content))}}
+	if _, _, err := d.download(context.Background(), "foo", "https://example.com/foo.zip", "", "", v); err == nil {
+		t.Fatal("download() with wrong sha256 expected to fail")
+	}
+
+	var events []Progress
+	d = Downloader{
+		Fetcher:  FakeFetcher{ioutil.NopCloser(strings.NewReader(content))},
+		Progress: ProgressFunc(func(p Progress) { events = append(events, p) }),
+	}
+	f, size, err := d.download(context.Background(), "foo", "https://example.com/foo.zip", "", "", newTrueVerifier())
+	if err != nil {
+		t.Fatalf("download() error = %v", err)
+	}
+	defer removeFile(f)
+	if size != int64(len(content)) {
+		t.Errorf("download() size = %d, want %d", size, len(content))
+	}
+	got, err := ioutil.ReadAll(io.NewSectionReader(f, 0, size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("download() content = %q, want %q", got, content)
+	}
+
+	// The fake fetcher does not know the size of the download.
+	n := int64(len(content))
+	want := []Progress{
+		{Label: "foo", Phase: PhaseDownload, Current: 0, Total: -1},
+		{Label: "foo", Phase: PhaseDownload, Current: n, Total: -1},
+		{Label: "foo", Phase: PhaseDownload, Current: n, Total: n, Finished: true},
+		{Label: "foo", Phase: PhaseVerify, Current: 0, Total: n},
+		{Label: "foo", Phase: PhaseVerify, Current: n, Total: n, Finished: true},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("download() progress = %+v, want %+v", events, want)
+	}
+}
+
+func TestGetWithSha256(t *testing.T) {
+	zipSrc := filepath.Join(testdataPath(), "test-with-directory.zip")
+	data, err := ioutil.ReadFile(zipSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	dst, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+
+	d := Downloader{Fetcher: FakeFetcher{ioutil.NopCloser(bytes.NewReader(data))}}
+	if err := d.GetWithSha256(context.Background(), "https://example.com/foo.zip", dst, hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("GetWithSha256() error = %v", err)
+	}
+	want := []string{"/test/", "/test/foo"}
+	if got := collectFiles(t, dst); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetWithSha256() extracted %#v, want %#v", got, want)
+	}
+}
@@ -15,24 +15,294 @@
 package download
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
 )
 
 // Fetcher is used to get files from a URI.
 type Fetcher interface {
-	// Get gets the file and returns an stream to read the file.
-	Get(uri string) (io.ReadCloser, error)
+	// Get gets the file and returns an stream to read the file. Canceling the
+	// context aborts the download.
+	Get(ctx context.Context, uri string) (io.ReadCloser, error)
+}
+
+// RangeFetcher is a Fetcher that can resume a download.
+type RangeFetcher interface {
+	Fetcher
+	// GetRange gets the file from the offset on. If the file can only be
+	// read from the start, the returned start is 0.
+	GetRange(ctx context.Context, uri string, offset int64) (body io.ReadCloser, start int64, err error)
+}
+
+// HTTPStatusError is returned when the server answers with a status code
+// other than 2xx.
+type HTTPStatusError struct {
+	URI        string
+	StatusCode int
+	Status     string
+
+	header http.Header
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.URI, e.Status)
+}
+
+// temporary checks if the request may succeed when it is retried.
+func (e *HTTPStatusError) temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500
 }
 
 // HTTPFetcher is used to get a file from a http:// or https:// schema path.
-type HTTPFetcher struct{}
+// Zero values disable the timeouts and retries, use NewHTTPFetcher to get the
+// defaults.
+type HTTPFetcher struct {
+	// Retries is how often a failed request is retried. Only network errors
+	// and status codes that indicate a temporary problem are retried.
+	Retries int
+	// Backoff is the wait before the first retry. It doubles for every
+	// following retry.
+	Backoff time.Duration
+	// ConnectTimeout limits connecting to the server, including the TLS
+	// handshake.
+	ConnectTimeout time.Duration
+	// ReadTimeout limits the time to wait for the response headers and for
+	// each read of the response body.
+	ReadTimeout time.Duration
+	// Proxy is used for all requests. If it is nil, the proxy is taken from
+	// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy *url.URL
+	// TLSConfig configures TLS connections, see TLSOptions.
+	TLSConfig *tls.Config
+
+	clientOnce sync.Once
+	client     *http.Client
+}
+
+// NewHTTPFetcher returns a HTTPFetcher with the default timeouts and retries.
+func NewHTTPFetcher() *HTTPFetcher {
+	return &HTTPFetcher{
+		Retries:        3,
+		Backoff:        time.Second,
+		ConnectTimeout: 30 * time.Second,
+		ReadTimeout:    time.Minute,
+	}
+}
+
+func (f *HTTPFetcher) httpClient() *http.Client {
+	f.clientOnce.Do(func() {
+		dialer := &net.Dialer{
+			Timeout:   f.ConnectTimeout,
+			KeepAlive: 30 * time.Second,
+		}
+		proxy := http.ProxyFromEnvironment
+		if f.Proxy != nil {
+			proxy = http.ProxyURL(f.Proxy)
+		}
+		f.client = &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 proxy,
+				TLSClientConfig:       f.TLSConfig,
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   f.ConnectTimeout,
+				ResponseHeaderTimeout: f.ReadTimeout,
+				IdleConnTimeout:       90 * time.Second,
+			},
+		}
+	})
+	return f.client
+}
 
 // Get gets the file and returns an stream to read the file.
-func (HTTPFetcher) Get(uri string) (io.ReadCloser, error) {
-	resp, err := http.Get(uri)
+func (f *HTTPFetcher) Get(ctx context.Context, uri string) (io.ReadCloser, error) {
+	return f.request(ctx, uri, nil)
+}
+
+// GetRange gets the file from the offset on with a Range request. If the
+// server ignores the range, the whole file is returned.
+func (f *HTTPFetcher) GetRange(ctx context.Context, uri string, offset int64) (io.ReadCloser, int64, error) {
+	body, err := f.request(ctx, uri, http.Header{"Range": {fmt.Sprintf("bytes=%d-", offset)}})
+	if statusErr, ok := err.(*HTTPStatusError); ok && statusErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The partial download is not a prefix of the file.
+		body, err = f.Get(ctx, uri)
+		return body, 0, err
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	info := body.(responseInfoer).responseInfo()
+	if info.status != http.StatusPartialContent {
+		return body, 0, nil
+	}
+	start, err := parseContentRangeStart(info.header.Get("Content-Range"))
+	if err != nil || start != offset {
+		body.Close()
+		return nil, 0, fmt.Errorf("GET %s: unexpected Content-Range %q for offset %d", uri, info.header.Get("Content-Range"), offset)
+	}
+	return body, start, nil
+}
+
+// parseContentRangeStart returns the first byte of a Content-Range like
+// "bytes 100-199/200".
+func parseContentRangeStart(s string) (int64, error) {
+	var start, end int64
+	if _, err := fmt.Sscanf(s, "bytes %d-%d", &start, &end); err != nil {
+		return 0, err
+	}
+	return start, nil
+}
+
+// request gets the file with additional request headers.
+func (f *HTTPFetcher) request(ctx context.Context, uri string, header http.Header) (io.ReadCloser, error) {
+	backoff := f.Backoff
+	for attempt := 0; ; attempt++ {
+		body, err := f.get(ctx, uri, header)
+		if err == nil {
+			return body, nil
+		}
+		if attempt >= f.Retries || !isTemporary(ctx, err) {
+			return nil, err
+		}
+		glog.V(1).Infof("Retrying download of %q in %v, err: %v", uri, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (f *HTTPFetcher) get(ctx context.Context, uri string, header http.Header) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
-	return resp.Body, nil
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := f.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		cancel()
+		return nil, &HTTPStatusError{URI: uri, StatusCode: resp.StatusCode, Status: resp.Status, header: resp.Header}
+	}
+	info := response{size: resp.ContentLength, status: resp.StatusCode, header: resp.Header}
+	if f.ReadTimeout <= 0 {
+		return &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel, response: info}, nil
+	}
+	return newTimeoutReader(resp.Body, info, f.ReadTimeout, cancel), nil
+}
+
+// response describes the HTTP response of a download stream.
+type response struct {
+	size   int64
+	status int
+	header http.Header
+}
+
+// responseInfoer is implemented by the download streams of HTTPFetcher.
+type responseInfoer interface {
+	responseInfo() response
+}
+
+func (r response) responseInfo() response { return r }
+
+// Size returns the Content-Length of the response, or -1 if it is unknown.
+func (r response) Size() int64 { return r.size }
+
+// AcceptsRanges checks if the server can resume the download.
+func (r response) AcceptsRanges() bool {
+	return r.status == http.StatusPartialContent || strings.Contains(r.header.Get("Accept-Ranges"), "bytes")
+}
+
+// isTemporary checks if a failed request should be retried. Timeouts and
+// refused or dropped connections are retried, but not errors that would
+// happen again, like invalid URIs or untrusted certificates.
+func isTemporary(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	switch err := err.(type) {
+	case *HTTPStatusError:
+		return err.temporary()
+	case *net.OpError:
+		if sysErr, ok := err.Err.(*os.SyscallError); ok && (sysErr.Err == syscall.ECONNREFUSED || sysErr.Err == syscall.ECONNRESET) {
+			return true
+		}
+		return err.Timeout() || err.Temporary()
+	case net.Error:
+		return err.Timeout() || err.Temporary()
+	}
+	// The server closed the connection before it answered.
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+// cancelReadCloser cancels the context of the request when it is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	response
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// timeoutReader aborts the request if a read takes longer than the timeout.
+type timeoutReader struct {
+	io.ReadCloser
+	response
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+}
+
+func newTimeoutReader(r io.ReadCloser, info response, timeout time.Duration, cancel context.CancelFunc) *timeoutReader {
+	return &timeoutReader{
+		ReadCloser: r,
+		response:   info,
+		timeout:    timeout,
+		timer:      time.AfterFunc(timeout, cancel),
+		cancel:     cancel,
+	}
+}
+
+func (t *timeoutReader) Read(p []byte) (int, error) {
+	t.timer.Reset(t.timeout)
+	n, err := t.ReadCloser.Read(p)
+	if !t.timer.Stop() && err != nil && err != io.EOF {
+		return n, fmt.Errorf("no data received for %v, err: %v", t.timeout, err)
+	}
+	return n, err
+}
+
+func (t *timeoutReader) Close() error {
+	t.timer.Stop()
+	defer t.cancel()
+	return t.ReadCloser.Close()
 }
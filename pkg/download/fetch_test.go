@@ -14,7 +14,22 @@
 
 package download
 
-import "io"
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
 
 // FakeFetcher is used for testing.
 type FakeFetcher struct {
@@ -22,6 +37,150 @@ type FakeFetcher struct {
 }
 
 // Get gets the file and returns an stream to read the file.
-func (ff FakeFetcher) Get(uri string) (io.ReadCloser, error) {
+func (ff FakeFetcher) Get(_ context.Context, uri string) (io.ReadCloser, error) {
 	return ff.ReadCloser, nil
 }
+
+func TestHTTPFetcher_Get(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      bool
+		wantStatus   int
+		wantRequests int32
+	}{
+		{name: "ok", statuses: []int{200}, wantRequests: 1},
+		{name: "retry server error", statuses: []int{503, 500, 200}, wantRequests: 3},
+		{name: "give up after retries", statuses: []int{503, 503, 503}, wantErr: true, wantStatus: 503, wantRequests: 3},
+		{name: "no retry on not found", statuses: []int{404, 200}, wantErr: true, wantStatus: 404, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&requests, 1)
+				w.WriteHeader(tt.statuses[n-1])
+				w.Write([]byte("content"))
+			}))
+			defer srv.Close()
+
+			f := &HTTPFetcher{Retries: 2, Backoff: time.Millisecond}
+			body, err := f.Get(context.Background(), srv.URL)
+			if got := atomic.LoadInt32(&requests); got != tt.wantRequests {
+				t.Errorf("Get() made %d requests, want %d", got, tt.wantRequests)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if statusErr, ok := err.(*HTTPStatusError); !ok || statusErr.StatusCode != tt.wantStatus {
+					t.Errorf("Get() error = %#v, want HTTPStatusError with status %d", err, tt.wantStatus)
+				}
+				return
+			}
+			defer body.Close()
+			if s, ok := body.(sizer); !ok || s.Size() != int64(len("content")) {
+				t.Errorf("Get() body does not report the Content-Length")
+			}
+			if data, err := ioutil.ReadAll(body); err != nil || string(data) != "content" {
+				t.Errorf("Get() body = %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcher_readTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	f := &HTTPFetcher{ReadTimeout: 50 * time.Millisecond}
+	body, err := f.Get(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if _, err := ioutil.ReadAll(body); err == nil {
+		t.Fatal("reading a stalled response should time out")
+	}
+}
+
+func TestHTTPFetcher_cancel(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &HTTPFetcher{Retries: 5, Backoff: time.Hour}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := f.Get(ctx, srv.URL); err != context.Canceled {
+		t.Errorf("Get() error = %v, want %v", err, context.Canceled)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Get() made %d requests after cancel, want 1", got)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return false }
+
+func Test_isTemporary(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "server error", err: &HTTPStatusError{StatusCode: 503}, want: true},
+		{name: "not found", err: &HTTPStatusError{StatusCode: 404}, want: false},
+		{name: "timeout", err: &url.Error{Op: "Get", Err: timeoutError{}}, want: true},
+		{name: "connection refused", err: &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}}, want: true},
+		{name: "connection reset", err: &url.Error{Op: "Get", Err: &net.OpError{Op: "read", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}}}, want: true},
+		{name: "dropped connection", err: &url.Error{Op: "Get", Err: io.EOF}, want: true},
+		{name: "unknown host", err: &url.Error{Op: "Get", Err: &net.DNSError{Err: "no such host", Name: "example.invalid"}}, want: false},
+		{name: "invalid URI", err: &url.Error{Op: "parse", Err: errors.New("invalid character")}, want: false},
+		{name: "untrusted certificate", err: &url.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTemporary(context.Background(), tt.err); got != tt.want {
+				t.Errorf("isTemporary(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcher_Get_tlsError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// The certificate of the test server is not trusted, a retry would wait
+	// for an hour.
+	f := &HTTPFetcher{Retries: 2, Backoff: time.Hour}
+	errs := make(chan error, 1)
+	go func() {
+		_, err := f.Get(context.Background(), srv.URL)
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("Get() expected a certificate error")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Get() retried a certificate error")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package download
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Fuzz targets for github.com/dvyukov/go-fuzz. The seed corpus of each target
+// is in testdata/fuzz/<target>/corpus, run them with:
+//
+//   mkdir /tmp/fuzz-tar && cp -r testdata/fuzz/FuzzExtractTAR/corpus /tmp/fuzz-tar
+//   go-fuzz-build -func FuzzExtractTAR github.com/GoogleContainerTools/krew/pkg/download
+//   go-fuzz -bin download-fuzz.zip -workdir /tmp/fuzz-tar
+//
+// "go test -tags gofuzz" runs the targets over their seed corpus.
+
+// FuzzExtractTAR extracts a fuzzed tar archive and panics if anything is
+// written outside of the target directory.
+func FuzzExtractTAR(data []byte) int {
+	return fuzzExtract(func(dir string) error {
+		return extractTAR(dir, bytes.NewReader(data), newExtractLimiter(DefaultLimits(), int64(len(data))))
+	})
+}
+
+// FuzzExtractZIP extracts a fuzzed zip archive and panics if anything is
+// written outside of the target directory.
+func FuzzExtractZIP(data []byte) int {
+	return fuzzExtract(func(dir string) error {
+		return extractZIP(dir, bytes.NewReader(data), int64(len(data)), newExtractLimiter(DefaultLimits(), int64(len(data))))
+	})
+}
+
+// fuzzExtract runs extract with a target directory in an empty parent
+// directory. It returns 1 for archives that were extracted, so go-fuzz
+// prefers them in the corpus.
+func fuzzExtract(extract func(targetDir string) error) int {
+	parent, err := ioutil.TempDir("", "krew-fuzz")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(parent)
+	targetDir := filepath.Join(parent, "target")
+	if err := os.Mkdir(targetDir, 0755); err != nil {
+		panic(err)
+	}
+	extractErr := extract(targetDir)
+	if names, err := filepath.Glob(filepath.Join(parent, "*")); err != nil {
+		panic(err)
+	} else if len(names) != 1 {
+		panic(fmt.Sprintf("extraction wrote outside of the target directory: %v", names))
+	}
+	if extractErr != nil {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,47 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package download
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFuzzCorpus(t *testing.T) {
+	for name, fuzz := range map[string]func([]byte) int{
+		"FuzzExtractTAR": FuzzExtractTAR,
+		"FuzzExtractZIP": FuzzExtractZIP,
+	} {
+		seeds, err := filepath.Glob(filepath.Join("testdata", "fuzz", name, "corpus", "*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(seeds) == 0 {
+			t.Errorf("%s has no seed corpus", name)
+		}
+		for _, seed := range seeds {
+			data, err := ioutil.ReadFile(seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The targets panic if an archive escapes the target directory.
+			fuzz(data)
+		}
+	}
+}
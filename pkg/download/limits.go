@@ -0,0 +1,178 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"fmt"
+	"io"
+)
+
+// Names of the limits in a LimitError.
+const (
+	LimitDownloadSize     = "download size"
+	LimitExtractedSize    = "extracted size"
+	LimitEntries          = "number of archive entries"
+	LimitCompressionRatio = "compression ratio"
+)
+
+// minRatioCheckSize is the extracted size in bytes below which the
+// compression ratio is not checked. Small archives of text files compress
+// well without being harmful.
+const minRatioCheckSize = 1 << 20
+
+// Limits bound the resources that a plugin archive can use. A zero value
+// disables a limit.
+type Limits struct {
+	// MaxDownloadSize is the size limit of a download in bytes.
+	MaxDownloadSize int64
+	// MaxExtractedSize is the limit of the total size of the extracted files
+	// in bytes.
+	MaxExtractedSize int64
+	// MaxEntries is the limit of the number of entries in an archive.
+	MaxEntries int
+	// MaxCompressionRatio is the limit of the extracted size divided by the
+	// size of the archive.
+	MaxCompressionRatio int64
+}
+
+// DefaultLimits returns limits that are large enough for every known
+// plugin.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxDownloadSize:     512 << 20,
+		MaxExtractedSize:    2 << 30,
+		MaxEntries:          10000,
+		MaxCompressionRatio: 100,
+	}
+}
+
+// LimitError is returned when a download or an archive exceeds one of the
+// Limits.
+type LimitError struct {
+	// Limit is the name of the exceeded limit, like LimitDownloadSize.
+	Limit string
+	// Max is the configured value of the limit.
+	Max int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("the %s exceeds the limit of %d", e.Limit, e.Max)
+}
+
+// limitReader reads from r until more than max bytes were read, then it fails
+// with a LimitError.
+type limitReader struct {
+	r     io.Reader
+	n     int64
+	max   int64
+	limit string
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n, &LimitError{Limit: l.limit, Max: l.max}
+	}
+	return n, err
+}
+
+// limitDownload limits the size of a download stream that is resumed at the
+// offset.
+func (l Limits) limitDownload(r io.Reader, offset int64) io.Reader {
+	if l.MaxDownloadSize <= 0 {
+		return r
+	}
+	return &limitReader{r: r, n: offset, max: l.MaxDownloadSize, limit: LimitDownloadSize}
+}
+
+// extractLimiter counts the entries and bytes of an archive while it is
+// extracted. A nil extractLimiter allows everything.
+type extractLimiter struct {
+	limits      Limits
+	archiveSize int64
+	entries     int
+	extracted   int64
+	// err is the first exceeded limit. Extractors wrap the errors of
+	// readers, so it is kept to return the LimitError to the caller.
+	err *LimitError
+}
+
+func newExtractLimiter(l Limits, archiveSize int64) *extractLimiter {
+	return &extractLimiter{limits: l, archiveSize: archiveSize}
+}
+
+// entry counts an archive entry.
+func (e *extractLimiter) entry() error {
+	if e == nil {
+		return nil
+	}
+	e.entries++
+	if max := e.limits.MaxEntries; max > 0 && e.entries > max {
+		return e.exceed(LimitEntries, int64(max))
+	}
+	return nil
+}
+
+// add counts extracted bytes.
+func (e *extractLimiter) add(n int64) error {
+	if e == nil {
+		return nil
+	}
+	e.extracted += n
+	if max := e.limits.MaxExtractedSize; max > 0 && e.extracted > max {
+		return e.exceed(LimitExtractedSize, max)
+	}
+	if max := e.limits.MaxCompressionRatio; max > 0 && e.extracted > minRatioCheckSize && e.extracted > max*e.archiveSize {
+		return e.exceed(LimitCompressionRatio, max)
+	}
+	return nil
+}
+
+func (e *extractLimiter) exceed(limit string, max int64) error {
+	if e.err == nil {
+		e.err = &LimitError{Limit: limit, Max: max}
+	}
+	return e.err
+}
+
+// exceeded returns the first limit that was exceeded, or nil.
+func (e *extractLimiter) exceeded() error {
+	if e == nil || e.err == nil {
+		return nil
+	}
+	return e.err
+}
+
+// reader counts the bytes that are extracted from r.
+func (e *extractLimiter) reader(r io.Reader) io.Reader {
+	if e == nil {
+		return r
+	}
+	return limitedExtraction{r: r, e: e}
+}
+
+type limitedExtraction struct {
+	r io.Reader
+	e *extractLimiter
+}
+
+func (l limitedExtraction) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if lerr := l.e.add(int64(n)); lerr != nil {
+		return n, lerr
+	}
+	return n, err
+}
@@ -0,0 +1,84 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func testTARGZ(t *testing.T, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(testTAR(t, entries)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownloader_GetInsecure_limits(t *testing.T) {
+	threeFiles := testTAR(t, []testEntry{
+		{name: "a", typ: tar.TypeReg, body: "a"},
+		{name: "b", typ: tar.TypeReg, body: "b"},
+		{name: "c", typ: tar.TypeReg, body: "c"},
+	})
+	zeros := testTARGZ(t, []testEntry{{name: "zeros", typ: tar.TypeReg, body: strings.Repeat("\x00", 4<<20)}})
+	tests := []struct {
+		name      string
+		in        []byte
+		limits    Limits
+		wantLimit string
+	}{
+		{name: "no limits", in: zeros},
+		{name: "within the limits", in: threeFiles, limits: DefaultLimits()},
+		{name: "download size", in: threeFiles, limits: Limits{MaxDownloadSize: 1024}, wantLimit: LimitDownloadSize},
+		{name: "entries", in: threeFiles, limits: Limits{MaxEntries: 2}, wantLimit: LimitEntries},
+		{name: "extracted size", in: zeros, limits: Limits{MaxExtractedSize: 1 << 20}, wantLimit: LimitExtractedSize},
+		{name: "compression ratio", in: zeros, limits: Limits{MaxCompressionRatio: 100}, wantLimit: LimitCompressionRatio},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "krew-limits-test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			d := Downloader{Fetcher: FakeFetcher{ioutil.NopCloser(bytes.NewReader(tt.in))}, Limits: tt.limits}
+			err = d.GetInsecure(context.Background(), "https://example.com/foo.tar", dir)
+			if tt.wantLimit == "" {
+				if err != nil {
+					t.Fatalf("GetInsecure() error = %v", err)
+				}
+				return
+			}
+			lerr, ok := err.(*LimitError)
+			if !ok {
+				t.Fatalf("GetInsecure() error = %v, want a LimitError", err)
+			}
+			if lerr.Limit != tt.wantLimit {
+				t.Errorf("GetInsecure() exceeded %q, want %q", lerr.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rewrite replaces the prefix of download URIs to get them from a mirror.
+type Rewrite struct {
+	// From is the prefix of the URIs to rewrite, like "https://github.com/".
+	From string
+	// To replaces the prefix, like "https://artifacts.example.com/github/".
+	To string
+}
+
+// ParseRewrite parses a rewrite in the form "<from>=<to>".
+func ParseRewrite(s string) (Rewrite, error) {
+	i := strings.Index(s, "=")
+	if i <= 0 || i == len(s)-1 {
+		return Rewrite{}, fmt.Errorf("mirror rewrite %q should have the form <from>=<to>", s)
+	}
+	return Rewrite{From: s[:i], To: s[i+1:]}, nil
+}
+
+// candidates returns the URIs to try for a download in order. URIs that are
+// rewritten to a mirror are tried before all others, so a blocked server is
+// only contacted if no mirror has the download.
+func (d Downloader) candidates(uris []string) []string {
+	var rewritten, out []string
+	for _, uri := range uris {
+		for _, r := range d.Rewrites {
+			if r.From != "" && strings.HasPrefix(uri, r.From) {
+				rewritten = append(rewritten, r.To+strings.TrimPrefix(uri, r.From))
+				break
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	for _, uri := range append(rewritten, uris...) {
+		if !seen[uri] {
+			seen[uri] = true
+			out = append(out, uri)
+		}
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRewrite(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rewrite
+		wantErr bool
+	}{
+		{in: "https://github.com/=https://mirror/github/", want: Rewrite{From: "https://github.com/", To: "https://mirror/github/"}},
+		{in: "https://github.com/", wantErr: true},
+		{in: "=https://mirror/", wantErr: true},
+		{in: "https://github.com/=", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRewrite(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRewrite(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRewrite(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDownloader_candidates(t *testing.T) {
+	d := Downloader{Rewrites: []Rewrite{
+		{From: "https://github.com/", To: "https://mirror/github/"},
+		{From: "https://github.com/foo/", To: "https://unused/"},
+	}}
+	got := d.candidates([]string{
+		"https://github.com/foo/a.zip",
+		"https://example.com/a.zip",
+		"https://mirror/github/foo/a.zip",
+	})
+	want := []string{
+		"https://mirror/github/foo/a.zip",
+		"https://github.com/foo/a.zip",
+		"https://example.com/a.zip",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("candidates() = %v, want %v", got, want)
+	}
+}
+
+func TestDownloader_Get_mirrors(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join(testdataPath(), "test-with-directory.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	dir, err := ioutil.TempDir("", "krew-mirror-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var finished []Progress
+	d := Downloader{
+		// The first URI is missing and the first mirror serves another file.
+		Fetcher: mapFetcher{
+			"https://mirror-a/a.zip": []byte("corrupt"),
+			"https://mirror-b/a.zip": data,
+		},
+		Progress: ProgressFunc(func(p Progress) {
+			if p.Phase == PhaseDownload && p.Finished {
+				finished = append(finished, p)
+			}
+		}),
+	}
+	uris := []string{"https://example.com/a.zip", "https://mirror-a/a.zip", "https://mirror-b/a.zip"}
+	if err := d.Get(context.Background(), uris, dir, Verification{Sha256: hex.EncodeToString(sum[:])}); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(finished) != 2 || finished[1].Mirror != "https://mirror-b/a.zip" {
+		t.Errorf("Get() finished downloads = %+v, want the last from https://mirror-b/a.zip", finished)
+	}
+
+	d.Fetcher = mapFetcher{"https://mirror-a/a.zip": []byte("corrupt")}
+	if err := d.Get(context.Background(), uris, dir, Verification{Sha256: hex.EncodeToString(sum[:])}); err == nil {
+		t.Error("Get() expected an error when no mirror has the archive")
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	ociTitleAnnotation      = "org.opencontainers.image.title"
+	// maxOCIManifestSize limits the size of a manifest, registries reject
+	// manifests larger than 4MiB.
+	maxOCIManifestSize = 4 << 20
+)
+
+var challengeParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// OCIFetcher gets plugin archives that are stored as OCI artifacts in a
+// container registry. URIs look like "oci://ghcr.io/foo/kubectl-foo:v1.0.0"
+// or "oci://ghcr.io/foo/kubectl-foo@sha256:<digest>". The archive is the
+// only layer of the artifact, or the layer whose title annotation is the
+// fragment of the URI, like "oci://ghcr.io/foo/plugins:v1#foo.tar.gz".
+// Registries are accessed anonymously.
+type OCIFetcher struct {
+	// HTTP talks to the registries.
+	HTTP *HTTPFetcher
+	// PlainHTTP lists registries, like "localhost:5000", that don't use TLS.
+	PlainHTTP []string
+}
+
+// ociReference is a parsed oci:// URI.
+type ociReference struct {
+	registry   string
+	repository string
+	// reference is a tag or a digest.
+	reference string
+	title     string
+}
+
+func parseOCIReference(uri string) (ociReference, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ociReference{}, fmt.Errorf("invalid URI %q, err: %v", uri, err)
+	}
+	repo := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || repo == "" {
+		return ociReference{}, fmt.Errorf("OCI URI %q should look like oci://<registry>/<repository>:<tag>", uri)
+	}
+	ref := ociReference{registry: u.Host, title: u.Fragment, reference: "latest"}
+	if i := strings.Index(repo, "@"); i >= 0 {
+		repo, ref.reference = repo[:i], repo[i+1:]
+	} else if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo, ref.reference = repo[:i], repo[i+1:]
+	}
+	if repo == "" || ref.reference == "" {
+		return ociReference{}, fmt.Errorf("OCI URI %q has no repository or reference", uri)
+	}
+	ref.repository = repo
+	return ref, nil
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// layer returns the layer with the archive.
+func (m ociManifest) layer(title string) (ociDescriptor, error) {
+	if title == "" {
+		if len(m.Layers) != 1 {
+			return ociDescriptor{}, fmt.Errorf("the artifact has %d layers, name one with the title in the URI fragment", len(m.Layers))
+		}
+		return m.Layers[0], nil
+	}
+	for _, l := range m.Layers {
+		if l.Annotations[ociTitleAnnotation] == title {
+			return l, nil
+		}
+	}
+	return ociDescriptor{}, fmt.Errorf("the artifact has no layer with the title %q", title)
+}
+
+// Get gets the archive layer of the artifact.
+func (o *OCIFetcher) Get(ctx context.Context, uri string) (io.ReadCloser, error) {
+	ref, err := parseOCIReference(uri)
+	if err != nil {
+		return nil, err
+	}
+	s := &ociSession{fetcher: o, ref: ref}
+	glog.V(2).Infof("Getting manifest %s of %s from %s", ref.reference, ref.repository, ref.registry)
+	body, err := s.get(ctx, "manifests/"+ref.reference, ociManifestMediaType+", "+dockerManifestMediaType)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxOCIManifestSize))
+	body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the manifest of %q, err: %v", uri, err)
+	}
+	var m ociManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse the manifest of %q, err: %v", uri, err)
+	}
+	layer, err := m.layer(ref.title)
+	if err != nil {
+		return nil, fmt.Errorf("can't get %q, err: %v", uri, err)
+	}
+	v, err := newDigestVerifier(layer.Digest)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(2).Infof("Getting layer %s of %s", layer.Digest, ref.repository)
+	body, err = s.get(ctx, "blobs/"+layer.Digest, "")
+	if err != nil {
+		return nil, err
+	}
+	return &digestReadCloser{ReadCloser: body, verifier: v, size: layer.Size}, nil
+}
+
+// ociSession keeps the token of the registry for the requests of one
+// download.
+type ociSession struct {
+	fetcher *OCIFetcher
+	ref     ociReference
+	token   string
+}
+
+func (s *ociSession) url(path string) string {
+	scheme := "https"
+	for _, r := range s.fetcher.PlainHTTP {
+		if r == s.ref.registry {
+			scheme = "http"
+		}
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s", scheme, s.ref.registry, s.ref.repository, path)
+}
+
+// get requests a path of the repository. If the registry asks for a token,
+// an anonymous token is requested and the request is repeated.
+func (s *ociSession) get(ctx context.Context, path, accept string) (io.ReadCloser, error) {
+	for {
+		header := make(http.Header)
+		if accept != "" {
+			header.Set("Accept", accept)
+		}
+		if s.token != "" {
+			header.Set("Authorization", "Bearer "+s.token)
+		}
+		body, err := s.fetcher.HTTP.request(ctx, s.url(path), header)
+		statusErr, ok := err.(*HTTPStatusError)
+		if !ok || statusErr.StatusCode != http.StatusUnauthorized || s.token != "" {
+			return body, err
+		}
+		if s.token, err = s.fetchToken(ctx, statusErr.header.Get("WWW-Authenticate")); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// fetchToken gets an anonymous pull token from the auth server that the
+// registry named in its challenge.
+func (s *ociSession) fetchToken(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", fmt.Errorf("registry %s requires unsupported authentication %q", s.ref.registry, challenge)
+	}
+	params := make(map[string]string)
+	for _, m := range challengeParamRegexp.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("registry %s sent an invalid token realm %q", s.ref.registry, params["realm"])
+	}
+	q := realm.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + s.ref.repository + ":pull"
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	body, err := s.fetcher.HTTP.request(ctx, realm.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a token for %s, err: %v", s.ref.registry, err)
+	}
+	defer body.Close()
+	var resp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, maxOCIManifestSize)).Decode(&resp); err != nil {
+		return "", fmt.Errorf("failed to parse the token for %s, err: %v", s.ref.registry, err)
+	}
+	if resp.Token != "" {
+		return resp.Token, nil
+	}
+	if resp.AccessToken != "" {
+		return resp.AccessToken, nil
+	}
+	return "", fmt.Errorf("the token server of %s sent no token", s.ref.registry)
+}
+
+// digestVerifier checks the content of a blob against its digest.
+type digestVerifier struct {
+	hash.Hash
+	digest string
+	want   []byte
+}
+
+func newDigestVerifier(digest string) (*digestVerifier, error) {
+	algorithm, encoded := "", ""
+	if i := strings.Index(digest, ":"); i >= 0 {
+		algorithm, encoded = digest[:i], digest[i+1:]
+	}
+	if algorithm != "sha256" {
+		return nil, fmt.Errorf("unsupported layer digest %q", digest)
+	}
+	want, err := hex.DecodeString(encoded)
+	if err != nil || len(want) != sha256.Size {
+		return nil, fmt.Errorf("invalid layer digest %q", digest)
+	}
+	return &digestVerifier{Hash: sha256.New(), digest: digest, want: want}, nil
+}
+
+// digestReadCloser fails at the end of the blob if it does not match its
+// digest.
+type digestReadCloser struct {
+	io.ReadCloser
+	verifier *digestVerifier
+	size     int64
+}
+
+func (d *digestReadCloser) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	d.verifier.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(d.verifier.Sum(nil), d.verifier.want) {
+		return n, fmt.Errorf("layer does not match its digest %s", d.verifier.digest)
+	}
+	return n, err
+}
+
+// Size returns the size of the layer from the manifest.
+func (d *digestReadCloser) Size() int64 { return d.size }
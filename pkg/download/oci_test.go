@@ -0,0 +1,173 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testRegistry is a stand-in for a registry:2 server with token
+// authentication. It serves the repository "plugins/foo".
+type testRegistry struct {
+	*httptest.Server
+	blobs     map[string]string
+	manifests map[string]ociManifest
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{blobs: make(map[string]string), manifests: make(map[string]ociManifest)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("scope") != "repository:plugins/foo:pull" {
+			http.Error(w, "wrong scope", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"token": "secret"}`)
+	})
+	mux.HandleFunc("/v2/plugins/foo/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, r.URL))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		path := strings.TrimPrefix(req.URL.Path, "/v2/plugins/foo/")
+		switch {
+		case strings.HasPrefix(path, "manifests/"):
+			m, ok := r.manifests[strings.TrimPrefix(path, "manifests/")]
+			if !ok {
+				http.NotFound(w, req)
+				return
+			}
+			w.Header().Set("Content-Type", ociManifestMediaType)
+			json.NewEncoder(w).Encode(m)
+		case strings.HasPrefix(path, "blobs/"):
+			blob, ok := r.blobs[strings.TrimPrefix(path, "blobs/")]
+			if !ok {
+				http.NotFound(w, req)
+				return
+			}
+			fmt.Fprint(w, blob)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	r.Server = httptest.NewServer(mux)
+	return r
+}
+
+// push adds a blob and returns its descriptor.
+func (r *testRegistry) push(title, content string) ociDescriptor {
+	digest := "sha256:" + sha256Hex(content)
+	r.blobs[digest] = content
+	return ociDescriptor{
+		MediaType:   "application/vnd.oci.image.layer.v1.tar",
+		Digest:      digest,
+		Size:        int64(len(content)),
+		Annotations: map[string]string{ociTitleAnnotation: title},
+	}
+}
+
+func TestOCIFetcher_Get(t *testing.T) {
+	reg := newTestRegistry(t)
+	defer reg.Close()
+	host := strings.TrimPrefix(reg.URL, "http://")
+
+	single := reg.push("foo.tar.gz", "single layer")
+	reg.manifests["v1"] = ociManifest{MediaType: ociManifestMediaType, Layers: []ociDescriptor{single}}
+	manifestDigest := "sha256:" + sha256Hex("v1 manifest")
+	reg.manifests[manifestDigest] = reg.manifests["v1"]
+	reg.manifests["multi"] = ociManifest{MediaType: ociManifestMediaType, Layers: []ociDescriptor{
+		reg.push("foo-linux.tar.gz", "linux"),
+		reg.push("foo-darwin.tar.gz", "darwin"),
+	}}
+	corrupt := reg.push("corrupt.tar.gz", "original")
+	reg.blobs[corrupt.Digest] = "modified"
+	reg.manifests["corrupt"] = ociManifest{MediaType: ociManifestMediaType, Layers: []ociDescriptor{corrupt}}
+
+	tests := []struct {
+		uri         string
+		want        string
+		wantErr     bool
+		wantReadErr bool
+	}{
+		{uri: "oci://" + host + "/plugins/foo:v1", want: "single layer"},
+		{uri: "oci://" + host + "/plugins/foo@" + manifestDigest, want: "single layer"},
+		{uri: "oci://" + host + "/plugins/foo:multi#foo-darwin.tar.gz", want: "darwin"},
+		{uri: "oci://" + host + "/plugins/foo:multi", wantErr: true},
+		{uri: "oci://" + host + "/plugins/foo:multi#missing.tar.gz", wantErr: true},
+		{uri: "oci://" + host + "/plugins/foo:v2", wantErr: true},
+		{uri: "oci://" + host + "/plugins/foo:corrupt", wantReadErr: true},
+	}
+	o := &OCIFetcher{HTTP: &HTTPFetcher{}, PlainHTTP: []string{host}}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			body, err := o.Get(context.Background(), tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer body.Close()
+			data, err := ioutil.ReadAll(body)
+			if (err != nil) != tt.wantReadErr {
+				t.Fatalf("Get() read error = %v, wantReadErr %v", err, tt.wantReadErr)
+			}
+			if err == nil && string(data) != tt.want {
+				t.Errorf("Get() = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseOCIReference(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    ociReference
+		wantErr bool
+	}{
+		{
+			uri:  "oci://ghcr.io/foo/kubectl-foo:v1.0.0",
+			want: ociReference{registry: "ghcr.io", repository: "foo/kubectl-foo", reference: "v1.0.0"},
+		},
+		{
+			uri:  "oci://localhost:5000/foo@sha256:abc#foo.tar.gz",
+			want: ociReference{registry: "localhost:5000", repository: "foo", reference: "sha256:abc", title: "foo.tar.gz"},
+		},
+		{
+			uri:  "oci://localhost:5000/foo",
+			want: ociReference{registry: "localhost:5000", repository: "foo", reference: "latest"},
+		},
+		{uri: "oci://ghcr.io", wantErr: true},
+		{uri: "oci://ghcr.io/foo:", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseOCIReference(tt.uri)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseOCIReference(%q) error = %v, wantErr %v", tt.uri, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseOCIReference(%q) = %+v, want %+v", tt.uri, got, tt.want)
+		}
+	}
+}
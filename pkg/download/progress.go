@@ -0,0 +1,119 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"io"
+)
+
+// Phase is a step of getting a plugin archive.
+type Phase string
+
+// Phases of getting a plugin archive, in the order they happen.
+const (
+	PhaseDownload Phase = "download"
+	PhaseVerify   Phase = "verify"
+	PhaseExtract  Phase = "extract"
+	PhaseMove     Phase = "move"
+)
+
+// Progress is an event about the progress of a phase.
+type Progress struct {
+	// Label names what is downloaded, see Downloader.Label.
+	Label string
+	Phase Phase
+	// Current is the number of bytes processed in the phase so far.
+	Current int64
+	// Total is the number of bytes of the phase, or -1 if it is unknown.
+	Total int64
+	// Finished is set on the last event of the phase.
+	Finished bool
+	// Mirror is set on the last event of a download phase if the archive was
+	// downloaded from a mirror, not from the URI of the manifest.
+	Mirror string
+}
+
+// ProgressReporter receives progress events. It may be called from several
+// goroutines at once.
+type ProgressReporter interface {
+	Report(Progress)
+}
+
+// ProgressFunc is a function that receives progress events.
+type ProgressFunc func(Progress)
+
+// Report calls the function.
+func (f ProgressFunc) Report(p Progress) { f(p) }
+
+// phaseReporter reports the events of a single phase.
+type phaseReporter struct {
+	reporter ProgressReporter
+	label    string
+	phase    Phase
+	total    int64
+	current  int64
+	mirror   string
+}
+
+func (d Downloader) startPhase(label string, phase Phase, total int64) *phaseReporter {
+	r := &phaseReporter{reporter: d.Progress, label: label, phase: phase, total: total}
+	r.report(false)
+	return r
+}
+
+func (r *phaseReporter) report(finished bool) {
+	if r.reporter == nil {
+		return
+	}
+	r.reporter.Report(Progress{Label: r.label, Phase: r.phase, Current: r.current, Total: r.total, Finished: finished, Mirror: r.mirror})
+}
+
+// add reports n more processed bytes.
+func (r *phaseReporter) add(n int64) {
+	r.current += n
+	if r.total >= 0 && r.current > r.total {
+		r.current = r.total
+	}
+	r.report(false)
+}
+
+// finish reports the end of the phase. If the total was unknown, it is set
+// to the processed bytes.
+func (r *phaseReporter) finish() {
+	if r.total < 0 {
+		r.total = r.current
+	} else {
+		r.current = r.total
+	}
+	r.report(true)
+}
+
+// Write counts written bytes, so the reporter can be used with io.TeeReader.
+func (r *phaseReporter) Write(p []byte) (int, error) {
+	r.add(int64(len(p)))
+	return len(p), nil
+}
+
+// progressReaderAt reports the bytes read from a io.ReaderAt.
+type progressReaderAt struct {
+	io.ReaderAt
+	r *phaseReporter
+}
+
+func (p progressReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := p.ReaderAt.ReadAt(b, off)
+	p.r.add(int64(n))
+	return n, err
+}
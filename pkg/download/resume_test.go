@@ -0,0 +1,233 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPFetcher_GetRange(t *testing.T) {
+	const content = "0123456789"
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		offset    int64
+		wantStart int64
+		wantBody  string
+		wantErr   bool
+	}{
+		{
+			name: "partial content",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.ServeContent(w, r, "a.zip", time.Time{}, bytes.NewReader([]byte(content)))
+			},
+			offset:    4,
+			wantStart: 4,
+			wantBody:  "456789",
+		},
+		{
+			name: "range ignored",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(content))
+			},
+			offset:   4,
+			wantBody: content,
+		},
+		{
+			name: "range not satisfiable",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Range") != "" {
+					w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+					return
+				}
+				w.Write([]byte(content))
+			},
+			offset:   20,
+			wantBody: content,
+		},
+		{
+			name: "wrong range",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", "bytes 2-9/10")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(content[2:]))
+			},
+			offset:  4,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			body, start, err := (&HTTPFetcher{}).GetRange(context.Background(), srv.URL, tt.offset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer body.Close()
+			if start != tt.wantStart {
+				t.Errorf("GetRange() start = %d, want %d", start, tt.wantStart)
+			}
+			if data, err := ioutil.ReadAll(body); err != nil || string(data) != tt.wantBody {
+				t.Errorf("GetRange() body = %q, %v, want %q", data, err, tt.wantBody)
+			}
+		})
+	}
+}
+
+// resumeServer serves an archive and drops the first response without a
+// Range header after half of the content.
+type resumeServer struct {
+	data []byte
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (s *resumeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	first := len(s.ranges) == 1
+	s.mu.Unlock()
+	if first && r.Header.Get("Range") == "" {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.data)))
+		w.Write(s.data[:len(s.data)/2])
+		return
+	}
+	http.ServeContent(w, r, "a.zip", time.Time{}, bytes.NewReader(s.data))
+}
+
+func TestDownloader_Get_resume(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join(testdataPath(), "test-with-directory.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	v := Verification{Sha256: hex.EncodeToString(sum[:])}
+	dir, err := ioutil.TempDir("", "krew-resume-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv := &resumeServer{data: data}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	uri := ts.URL + "/a.zip"
+
+	d := Downloader{Fetcher: &HTTPFetcher{}, PartialDir: filepath.Join(dir, "partial")}
+	if err := d.Get(context.Background(), []string{uri}, filepath.Join(dir, "a"), v); err == nil {
+		t.Fatal("Get() expected an error for the dropped download")
+	}
+	partial := d.partialPath(uri, v)
+	if fi, err := os.Stat(partial); err != nil || fi.Size() != int64(len(data)/2) {
+		t.Fatalf("partial download was not kept, err: %v", err)
+	}
+
+	if err := d.Get(context.Background(), []string{uri}, filepath.Join(dir, "a"), v); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if want := "bytes=" + strconv.Itoa(len(data)/2) + "-"; len(srv.ranges) != 2 || srv.ranges[1] != want {
+		t.Errorf("Get() requests with ranges %q, want the second with %q", srv.ranges, want)
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("partial download was not removed, err: %v", err)
+	}
+
+	// A partial file from other content fails the verification and the
+	// download starts again.
+	if err := ioutil.WriteFile(partial, []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv.ranges = nil
+	if err := d.Get(context.Background(), []string{uri}, filepath.Join(dir, "b"), v); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(srv.ranges) != 2 || srv.ranges[0] != "bytes=7-" || srv.ranges[1] != "" {
+		t.Errorf("Get() requests with ranges %q, want a resume and a full download", srv.ranges)
+	}
+}
+
+func TestDownloader_partialPath(t *testing.T) {
+	d := Downloader{PartialDir: "/partial"}
+	if got := d.partialPath("https://example.com/a.zip", Verification{}); got != "" {
+		t.Errorf("partialPath() = %q for a download without checksum, want none", got)
+	}
+	a := d.partialPath("https://example.com/a.zip", Verification{Sha256: "aa"})
+	b := d.partialPath("https://example.com/a.zip", Verification{Sha256: "bb"})
+	if a == "" || a == b || filepath.Dir(a) != "/partial" {
+		t.Errorf("partialPath() = %q and %q, want distinct files in /partial", a, b)
+	}
+	if got := (Downloader{}).partialPath("https://example.com/a.zip", Verification{Sha256: "aa"}); got != "" {
+		t.Errorf("partialPath() = %q without a partial dir, want none", got)
+	}
+}
+
+func TestDownloader_Get_partialInUse(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join(testdataPath(), "test-with-directory.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	v := Verification{Sha256: hex.EncodeToString(sum[:])}
+	dir, err := ioutil.TempDir("", "krew-resume-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer ts.Close()
+	uri := ts.URL + "/a.zip"
+
+	// Another download of the same archive writes the partial file.
+	d := Downloader{Fetcher: &HTTPFetcher{}, PartialDir: filepath.Join(dir, "partial")}
+	partial := d.partialPath(uri, v)
+	if err := os.MkdirAll(filepath.Dir(partial), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(partial, data[:10], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !lockPartial(partial) {
+		t.Fatal("lockPartial() = false")
+	}
+	defer unlockPartial(partial)
+
+	if err := d.Get(context.Background(), []string{uri}, filepath.Join(dir, "a"), v); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if content, err := ioutil.ReadFile(partial); err != nil || !bytes.Equal(content, data[:10]) {
+		t.Errorf("partial download in use was changed, err: %v", err)
+	}
+}
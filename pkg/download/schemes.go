@@ -0,0 +1,250 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+// Schemes is a Fetcher that gets a URI with the Fetcher registered for its
+// scheme.
+type Schemes map[string]Fetcher
+
+// NewSchemes returns the built-in fetchers for http://, https://, file://
+// and oci:// URIs. The HTTP fetcher is also used to talk to OCI registries.
+func NewSchemes(h *HTTPFetcher) Schemes {
+	return Schemes{
+		"http":  h,
+		"https": h,
+		"file":  FileFetcher{},
+		"oci":   &OCIFetcher{HTTP: h},
+	}
+}
+
+// DefaultIndexSchemes are the URI schemes that manifests of plugin indexes
+// can use unless the user allows more. Local files and helper commands are
+// only used for manifests of an index if the user asks for it.
+var DefaultIndexSchemes = []string{"http", "https", "oci"}
+
+// Restrict returns the fetchers for the allowed schemes only. URIs with the
+// other schemes of s fail with an error that names the scheme.
+func (s Schemes) Restrict(allowed []string) Schemes {
+	r := make(Schemes, len(s))
+	for scheme := range s {
+		r[scheme] = disallowedScheme(scheme)
+	}
+	for _, scheme := range allowed {
+		scheme = strings.ToLower(scheme)
+		if f, ok := s[scheme]; ok {
+			r[scheme] = f
+		}
+	}
+	return r
+}
+
+// disallowedScheme is the fetcher of a scheme that is not allowed.
+type disallowedScheme string
+
+func (d disallowedScheme) Get(_ context.Context, uri string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("the scheme %q of %q is not allowed for plugins of an index", string(d), uri)
+}
+
+// Get gets the file with the fetcher for the scheme of the URI.
+func (s Schemes) Get(ctx context.Context, uri string) (io.ReadCloser, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URI %q, err: %v", uri, err)
+	}
+	f, ok := s[strings.ToLower(u.Scheme)]
+	if !ok {
+		return nil, fmt.Errorf("no fetcher for the scheme %q of %q", u.Scheme, uri)
+	}
+	return f.Get(ctx, uri)
+}
+
+// GetRange resumes the download if the fetcher for the scheme of the URI
+// supports it.
+func (s Schemes) GetRange(ctx context.Context, uri string, offset int64) (io.ReadCloser, int64, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid URI %q, err: %v", uri, err)
+	}
+	if f, ok := s[strings.ToLower(u.Scheme)].(RangeFetcher); ok {
+		return f.GetRange(ctx, uri, offset)
+	}
+	body, err := s.Get(ctx, uri)
+	return body, 0, err
+}
+
+// FileFetcher gets files from file:// URIs, for air-gapped installations.
+type FileFetcher struct{}
+
+// Get opens the file.
+func (FileFetcher) Get(_ context.Context, uri string) (io.ReadCloser, error) {
+	path, err := filePath(uri)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("%q is not a regular file", path)
+	}
+	return &sizedReadCloser{ReadCloser: f, size: fi.Size()}, nil
+}
+
+// filePath returns the local path of a file:// URI. Relative paths like
+// "file:plugins/foo.zip" are allowed.
+func filePath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid URI %q, err: %v", uri, err)
+	}
+	if u.Opaque != "" {
+		return filepath.FromSlash(u.Opaque), nil
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("file URI %q can't have a remote host", uri)
+	}
+	p := u.Path
+	// file:///C:/foo.zip has the path /C:/foo.zip.
+	if runtime.GOOS == "windows" && len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p), nil
+}
+
+// sizedReadCloser is a stream with a known size.
+type sizedReadCloser struct {
+	io.ReadCloser
+	size int64
+}
+
+// Size returns the size of the stream in bytes.
+func (s *sizedReadCloser) Size() int64 { return s.size }
+
+// maxHelperStderr limits the output of a helper that is kept for the error
+// message.
+const maxHelperStderr = 4096
+
+// HelperFetcher gets files with an external command. The command is called
+// with the URI as the last argument and writes the file to stdout. It must
+// exit with status 0 if the download succeeded.
+type HelperFetcher struct {
+	Command string
+	Args    []string
+}
+
+// ParseHelperFetcher parses a command line like "krew-fetch-s3 --profile
+// corp" into a HelperFetcher.
+func ParseHelperFetcher(commandLine string) (HelperFetcher, error) {
+	fields := strings.Fields(commandLine)
+	if len(fields) == 0 {
+		return HelperFetcher{}, fmt.Errorf("the fetcher command is empty")
+	}
+	return HelperFetcher{Command: fields[0], Args: fields[1:]}, nil
+}
+
+// Get starts the command and streams its output.
+func (h HelperFetcher) Get(ctx context.Context, uri string) (io.ReadCloser, error) {
+	glog.V(2).Infof("Fetching %q with %s", uri, h.Command)
+	cmd := exec.CommandContext(ctx, h.Command, append(append([]string{}, h.Args...), uri)...)
+	stderr := &limitedBuffer{max: maxHelperStderr}
+	cmd.Stderr = stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start fetcher %q, err: %v", h.Command, err)
+	}
+	return &helperReader{ReadCloser: out, cmd: cmd, stderr: stderr}, nil
+}
+
+// helperReader reads the output of a helper command. Reaching the end of
+// the output fails if the command failed.
+type helperReader struct {
+	io.ReadCloser
+	cmd     *exec.Cmd
+	stderr  *limitedBuffer
+	waited  bool
+	waitErr error
+}
+
+func (r *helperReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		if werr := r.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (r *helperReader) wait() error {
+	if !r.waited {
+		r.waited = true
+		if err := r.cmd.Wait(); err != nil {
+			r.waitErr = fmt.Errorf("fetcher %q failed, err: %v: %s", r.cmd.Path, err, strings.TrimSpace(r.stderr.String()))
+		}
+	}
+	return r.waitErr
+}
+
+// Close stops the command if its output was not read to the end.
+func (r *helperReader) Close() error {
+	if !r.waited && r.cmd.Process != nil {
+		r.cmd.Process.Kill()
+	}
+	r.ReadCloser.Close()
+	r.wait()
+	return nil
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if rest := b.max - b.Len(); rest > 0 {
+		if len(p) > rest {
+			b.Buffer.Write(p[:rest])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
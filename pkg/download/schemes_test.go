@@ -0,0 +1,163 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSchemes_Get(t *testing.T) {
+	s := Schemes{"fake": FakeFetcher{ioutil.NopCloser(strings.NewReader("fake"))}}
+	body, err := s.Get(context.Background(), "FAKE://host/foo.zip")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer body.Close()
+	if data, _ := ioutil.ReadAll(body); string(data) != "fake" {
+		t.Errorf("Get() = %q, want %q", data, "fake")
+	}
+	if _, err := s.Get(context.Background(), "ftp://host/foo.zip"); err == nil {
+		t.Error("Get() expected an error for a scheme without fetcher")
+	}
+}
+
+func TestSchemes_Restrict(t *testing.T) {
+	fake := FakeFetcher{ioutil.NopCloser(strings.NewReader("fake"))}
+	s := Schemes{"https": fake, "file": fake, "s3": fake}.Restrict([]string{"HTTPS", "ftp"})
+	if _, err := s.Get(context.Background(), "https://host/foo.zip"); err != nil {
+		t.Errorf("Get() error = %v for an allowed scheme", err)
+	}
+	for _, uri := range []string{"file:///foo.zip", "s3://bucket/foo.zip", "ftp://host/foo.zip"} {
+		if _, err := s.Get(context.Background(), uri); err == nil {
+			t.Errorf("Get(%q) expected an error for a scheme that is not allowed", uri)
+		}
+	}
+}
+
+func TestFileFetcher_Get(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krew-file-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "foo.zip")
+	if err := ioutil.WriteFile(path, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uri := "file://" + filepath.ToSlash(path)
+	if runtime.GOOS == "windows" {
+		uri = "file:///" + filepath.ToSlash(path)
+	}
+
+	body, err := FileFetcher{}.Get(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("Get(%q) error = %v", uri, err)
+	}
+	defer body.Close()
+	if size := body.(sizer).Size(); size != int64(len("content")) {
+		t.Errorf("Get(%q).Size() = %d, want %d", uri, size, len("content"))
+	}
+	if data, _ := ioutil.ReadAll(body); string(data) != "content" {
+		t.Errorf("Get(%q) = %q, want %q", uri, data, "content")
+	}
+
+	for _, uri := range []string{
+		"file://" + filepath.ToSlash(filepath.Join(dir, "missing.zip")),
+		"file://" + filepath.ToSlash(dir),
+		"file://example.com/foo.zip",
+	} {
+		if _, err := (FileFetcher{}).Get(context.Background(), uri); err == nil {
+			t.Errorf("Get(%q) expected an error", uri)
+		}
+	}
+}
+
+func Test_filePath(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: "file:///tmp/foo.zip", want: "/tmp/foo.zip"},
+		{uri: "file://localhost/tmp/foo.zip", want: "/tmp/foo.zip"},
+		{uri: "file:plugins/foo.zip", want: "plugins/foo.zip"},
+	}
+	for _, tt := range tests {
+		got, err := filePath(tt.uri)
+		if err != nil {
+			t.Errorf("filePath(%q) error = %v", tt.uri, err)
+			continue
+		}
+		if want := filepath.FromSlash(tt.want); got != want {
+			t.Errorf("filePath(%q) = %q, want %q", tt.uri, got, want)
+		}
+	}
+}
+
+func TestHelperFetcher_Get(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	tests := []struct {
+		name    string
+		script  string
+		want    string
+		wantErr bool
+	}{
+		{name: "prints the URI", script: `printf %s "$0"`, want: "s3://bucket/foo.zip"},
+		{name: "fails", script: `printf partial; echo denied >&2; exit 3`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := HelperFetcher{Command: "sh", Args: []string{"-c", tt.script}}
+			body, err := h.Get(context.Background(), "s3://bucket/foo.zip")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer body.Close()
+			data, err := ioutil.ReadAll(body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() read error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), "denied") {
+					t.Errorf("Get() error = %v, want the stderr of the helper", err)
+				}
+				return
+			}
+			if string(data) != tt.want {
+				t.Errorf("Get() = %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHelperFetcher(t *testing.T) {
+	h, err := ParseHelperFetcher("krew-fetch-s3 --profile corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Command != "krew-fetch-s3" || strings.Join(h.Args, " ") != "--profile corp" {
+		t.Errorf("ParseHelperFetcher() = %+v", h)
+	}
+	if _, err := ParseHelperFetcher("  "); err == nil {
+		t.Error("ParseHelperFetcher() expected an error for an empty command")
+	}
+}
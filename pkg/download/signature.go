@@ -0,0 +1,127 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// publicKeyPrefix is the prefix of ed25519 public keys in manifests.
+const publicKeyPrefix = "ed25519:"
+
+// SignatureSuffix is appended to the path of a download URI to get the URI
+// of its detached signature.
+const SignatureSuffix = ".sig"
+
+// maxSignatureSize limits the size of a signature file. A base64 encoded
+// ed25519 signature has 88 bytes.
+const maxSignatureSize = 4096
+
+// ParsePublicKey parses a public key in the form "ed25519:<base64 key>".
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(s, publicKeyPrefix) {
+		return nil, fmt.Errorf("public key %q should start with %q", s, publicKeyPrefix)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, publicKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("public key %q is not base64 encoded, err: %v", s, err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key %q has %d bytes, expected %d", s, len(raw), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// parseSignature parses a base64 encoded ed25519 signature.
+func parseSignature(s string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("signature is not base64 encoded, err: %v", err)
+	}
+	if len(raw) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("signature has %d bytes, expected %d", len(raw), ed25519.SignatureSize)
+	}
+	return raw, nil
+}
+
+var _ verifier = signatureVerifier{}
+
+// signatureVerifier checks a plain Ed25519 signature of the 64 byte sha512
+// sum of the download. The download doesn't have to be kept in memory to
+// check it.
+type signatureVerifier struct {
+	hash.Hash
+	key       ed25519.PublicKey
+	signature []byte
+}
+
+func newSignatureVerifier(key ed25519.PublicKey, signature []byte) verifier {
+	return signatureVerifier{Hash: sha512.New(), key: key, signature: signature}
+}
+
+func (v signatureVerifier) Verify() error {
+	if !ed25519.Verify(v.key, v.Sum(nil), v.signature) {
+		return fmt.Errorf("signature does not match the public key")
+	}
+	return nil
+}
+
+// SignatureURI returns the URI of the detached signature of a download. The
+// signature of an OCI artifact layer is the layer with the same title and
+// the suffix.
+func SignatureURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri + SignatureSuffix
+	}
+	if u.Scheme == "oci" && u.Fragment != "" {
+		u.Fragment += SignatureSuffix
+		return u.String()
+	}
+	u.Path += SignatureSuffix
+	if u.RawPath != "" {
+		u.RawPath += SignatureSuffix
+	}
+	return u.String()
+}
+
+// fetchSignature downloads the detached signature of a download.
+func (d Downloader) fetchSignature(ctx context.Context, uri string) ([]byte, error) {
+	sigURI := SignatureURI(uri)
+	body, err := d.Fetcher.Get(ctx, sigURI)
+	if err != nil {
+		return nil, fmt.Errorf("could not download signature %q, err: %v", sigURI, err)
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxSignatureSize))
+	if err != nil {
+		return nil, fmt.Errorf("could not read signature %q, err: %v", sigURI, err)
+	}
+	sig, err := parseSignature(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature %q, err: %v", sigURI, err)
+	}
+	return sig, nil
+}
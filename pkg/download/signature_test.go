@@ -0,0 +1,210 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// mapFetcher serves the content of the map for each URI.
+type mapFetcher map[string][]byte
+
+func (m mapFetcher) Get(_ context.Context, uri string) (io.ReadCloser, error) {
+	data, ok := m[uri]
+	if !ok {
+		return nil, fmt.Errorf("%q not found", uri)
+	}
+	return ioutil.NopCloser(strings.NewReader(string(data))), nil
+}
+
+// sign returns the public key in manifest format and the Ed25519 signature
+// of the sha512 sum of the data.
+func sign(t *testing.T, data []byte) (string, []byte) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha512.Sum512(data)
+	sig := ed25519.Sign(priv, digest[:])
+	return publicKeyPrefix + base64.StdEncoding.EncodeToString(pub), []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+}
+
+func TestParsePublicKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		wantErr bool
+	}{
+		{key: "ed25519:11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="},
+		{key: "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=", wantErr: true},
+		{key: "ed25519:not base64", wantErr: true},
+		{key: "ed25519:c2hvcnQ=", wantErr: true},
+	}
+	for _, tt := range tests {
+		if _, err := ParsePublicKey(tt.key); (err != nil) != tt.wantErr {
+			t.Errorf("ParsePublicKey(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSignatureURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: "https://example.com/foo.tar.gz", want: "https://example.com/foo.tar.gz.sig"},
+		{uri: "https://example.com/dl/foo.zip?raw=true", want: "https://example.com/dl/foo.zip.sig?raw=true"},
+		{uri: "oci://ghcr.io/foo/plugins:v1#foo.tar.gz", want: "oci://ghcr.io/foo/plugins:v1#foo.tar.gz.sig"},
+	}
+	for _, tt := range tests {
+		if got := SignatureURI(tt.uri); got != tt.want {
+			t.Errorf("SignatureURI(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestDownloader_Get_signature(t *testing.T) {
+	const uri = "https://example.com/test-with-directory.zip"
+	data, err := ioutil.ReadFile(filepath.Join(testdataPath(), "test-with-directory.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, sig := sign(t, data)
+	otherKey, _ := sign(t, data)
+	sum := sha512.Sum512(data)
+
+	tests := []struct {
+		name    string
+		files   mapFetcher
+		v       Verification
+		wantErr bool
+	}{
+		{
+			name:  "signed",
+			files: mapFetcher{uri: data, uri + ".sig": sig},
+			v:     Verification{PublicKey: key},
+		},
+		{
+			name:  "signed with sha512",
+			files: mapFetcher{uri: data, uri + ".sig": sig},
+			v:     Verification{PublicKey: key, Sha512: fmt.Sprintf("%x", sum)},
+		},
+		{
+			name:    "wrong sha512",
+			files:   mapFetcher{uri: data},
+			v:       Verification{Sha512: strings.Repeat("0", 128)},
+			wantErr: true,
+		},
+		{
+			name:    "other key",
+			files:   mapFetcher{uri: data, uri + ".sig": sig},
+			v:       Verification{PublicKey: otherKey},
+			wantErr: true,
+		},
+		{
+			name:    "missing signature",
+			files:   mapFetcher{uri: data},
+			v:       Verification{PublicKey: key},
+			wantErr: true,
+		},
+		{
+			name:    "modified download",
+			files:   mapFetcher{uri: append([]byte{}, data[:len(data)-1]...), uri + ".sig": sig},
+			v:       Verification{PublicKey: key},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "krew-signature-test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			d := Downloader{Fetcher: tt.files}
+			if err := d.Get(context.Background(), []string{uri}, dir, tt.v); (err != nil) != tt.wantErr {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloader_Get_signatureCached(t *testing.T) {
+	const uri = "https://example.com/test-with-directory.zip"
+	data, err := ioutil.ReadFile(filepath.Join(testdataPath(), "test-with-directory.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, sig := sign(t, data)
+	otherKey, otherSig := sign(t, data)
+	sha := sha256Hex(string(data))
+
+	cache, cleanup := newTestCache(t, 0)
+	defer cleanup()
+	if err := cache.add(sha, strings.NewReader(string(data)), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+
+	// The archive itself is not fetched, but its signature is checked and
+	// added to the cache.
+	d := Downloader{Fetcher: mapFetcher{uri + ".sig": sig}, Cache: &cache}
+	if err := d.Get(context.Background(), []string{uri}, filepath.Join(cache.Dir, "a"), Verification{Sha256: sha, PublicKey: key}); err != nil {
+		t.Errorf("Get() error = %v for the cached archive", err)
+	}
+	if got, ok := cache.signature(sha); !ok || base64.StdEncoding.EncodeToString(got) != strings.TrimSpace(string(sig)) {
+		t.Errorf("cached signature = %v, %v, want the signature of the archive", got, ok)
+	}
+
+	// The cached signature is checked without the network.
+	d.Fetcher = mapFetcher{}
+	if err := d.Get(context.Background(), []string{uri}, filepath.Join(cache.Dir, "b"), Verification{Sha256: sha, PublicKey: key}); err != nil {
+		t.Errorf("Get() error = %v for the cached archive and signature", err)
+	}
+
+	rawOtherSig, err := parseSignature(string(otherSig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.addSignature(sha, rawOtherSig); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Get(context.Background(), []string{uri}, filepath.Join(cache.Dir, "c"), Verification{Sha256: sha, PublicKey: key}); err == nil {
+		t.Error("Get() expected an error for a cached archive with another signature")
+	}
+	if entries, _ := cache.List(); len(entries) != 0 {
+		t.Errorf("cached archive with another signature was not removed, cache has %v", entries)
+	}
+	if _, ok := cache.signature(sha); ok {
+		t.Error("signature that fails the verification was not removed from the cache")
+	}
+
+	d.Fetcher = mapFetcher{uri + ".sig": otherSig}
+	if err := cache.add(sha, strings.NewReader(string(data)), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Get(context.Background(), []string{uri}, filepath.Join(cache.Dir, "d"), Verification{Sha256: sha, Sha512: strings.Repeat("0", 128), PublicKey: otherKey}); err == nil {
+		t.Error("Get() expected an error for a cached archive with another sha512")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/golang/glog"
+)
+
+// TLSOptions configures the TLS connections of downloads.
+type TLSOptions struct {
+	// CAFiles are PEM files with certificates that are trusted in addition
+	// to the system roots.
+	CAFiles []string
+	// ClientCert and ClientKey are PEM files with a client certificate that
+	// is presented to servers that ask for one.
+	ClientCert string
+	ClientKey  string
+}
+
+// Config returns the TLS configuration for the options. It returns nil if no
+// option is set, so the defaults are used.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	if len(o.CAFiles) == 0 && o.ClientCert == "" && o.ClientKey == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{}
+	if len(o.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			glog.V(1).Infof("Failed to load the system certificates, only trusting the configured CA files, err: %v", err)
+			pool = x509.NewCertPool()
+		}
+		for _, f := range o.CAFiles {
+			pem, err := ioutil.ReadFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("could not read CA file, err: %v", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("CA file %q does not contain PEM certificates", f)
+			}
+		}
+		cfg.RootCAs = pool
+	}
+	if o.ClientCert != "" || o.ClientKey != "" {
+		if o.ClientCert == "" || o.ClientKey == "" {
+			return nil, fmt.Errorf("client certificate and key have both to be set")
+		}
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate, err: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,79 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package download
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTLSOptions_Config(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "tls-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (&HTTPFetcher{}).Get(context.Background(), srv.URL); err == nil {
+		t.Fatal("Get() trusted the test server without its CA")
+	}
+
+	cfg, err := TLSOptions{CAFiles: []string{caFile}}.Config()
+	if err != nil {
+		t.Fatalf("Config() error = %v", err)
+	}
+	body, err := (&HTTPFetcher{TLSConfig: cfg}).Get(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Get() with CA file error = %v", err)
+	}
+	body.Close()
+}
+
+func TestTLSOptions_Config_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts TLSOptions
+	}{
+		{name: "missing CA file", opts: TLSOptions{CAFiles: []string{"/does/not/exist.pem"}}},
+		{name: "CA file without certificates", opts: TLSOptions{CAFiles: []string{filepath.Join(testdataPath(), "test-with-directory.zip")}}},
+		{name: "client cert without key", opts: TLSOptions{ClientCert: "cert.pem"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.opts.Config(); err == nil {
+				t.Error("Config() expected an error")
+			}
+		})
+	}
+	if cfg, err := (TLSOptions{}).Config(); cfg != nil || err != nil {
+		t.Errorf("Config() without options = %v, %v, want nil", cfg, err)
+	}
+}
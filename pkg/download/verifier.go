@@ -17,6 +17,7 @@ package download
 import (
 	"bytes"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -30,27 +31,69 @@ type verifier interface {
 	Verify() error
 }
 
-var _ verifier = sha256Verifier{}
+var _ verifier = hashVerifier{}
 
-type sha256Verifier struct {
+type hashVerifier struct {
 	hash.Hash
+	name       string
 	wantedHash []byte
 }
 
-// newSha256Verifier creates a Verifier that tests against the given hash.
-func newSha256Verifier(hash string) verifier {
-	raw, _ := hex.DecodeString(hash)
-	return sha256Verifier{
-		Hash:       sha256.New(),
-		wantedHash: raw,
+// newSha256Verifier creates a Verifier that tests against the given hash. It
+// fails if the hash is not a hex encoded sha256 sum.
+func newSha256Verifier(hash string) (verifier, error) {
+	return newHashVerifier("sha256", sha256.New(), hash)
+}
+
+// newSha512Verifier creates a Verifier that tests against the given hash. It
+// fails if the hash is not a hex encoded sha512 sum.
+func newSha512Verifier(hash string) (verifier, error) {
+	return newHashVerifier("sha512", sha512.New(), hash)
+}
+
+func newHashVerifier(name string, h hash.Hash, hash string) (verifier, error) {
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, fmt.Errorf("%s %q is not hex encoded, err: %v", name, hash, err)
+	}
+	if len(raw) != h.Size() {
+		return nil, fmt.Errorf("%s %q has %d bytes, expected %d", name, hash, len(raw), h.Size())
 	}
+	return hashVerifier{
+		Hash:       h,
+		name:       name,
+		wantedHash: raw,
+	}, nil
 }
 
-func (v sha256Verifier) Verify() error {
+func (v hashVerifier) Verify() error {
 	if bytes.Equal(v.wantedHash, v.Sum(nil)) {
 		return nil
 	}
-	return fmt.Errorf("hash does not match, want: %x, got %x", v.wantedHash, v.Sum(nil))
+	return fmt.Errorf("%s hash does not match, want: %x, got %x", v.name, v.wantedHash, v.Sum(nil))
+}
+
+var _ verifier = multiVerifier{}
+
+// multiVerifier passes if all of its verifiers pass.
+type multiVerifier []verifier
+
+func (m multiVerifier) Write(p []byte) (int, error) {
+	for _, v := range m {
+		if _, err := v.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (m multiVerifier) Verify() error {
+	for _, v := range m {
+		if err := v.Verify(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 var _ verifier = trueVerifier{}
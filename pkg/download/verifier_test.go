@@ -49,7 +49,10 @@ func TestSha256Verifier(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			v := newSha256Verifier(tt.args.hash)
+			v, err := newSha256Verifier(tt.args.hash)
+			if err != nil {
+				t.Fatalf("newSha256Verifier(%s) error = %v", tt.args.hash, err)
+			}
 			io.Copy(v, bytes.NewReader(tt.write))
 			if err := v.Verify(); (err != nil) != tt.wantError {
 				t.Errorf("newSha256Verifier().Write(%x).Verify() = %v, want %v", tt.write, err, tt.wantError)
@@ -59,6 +62,45 @@ func TestSha256Verifier(t *testing.T) {
 	}
 }
 
+func TestSha256Verifier_malformed(t *testing.T) {
+	for _, hash := range []string{
+		"not-hex",
+		"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcd",
+		"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9ff",
+	} {
+		if _, err := newSha256Verifier(hash); err == nil {
+			t.Errorf("newSha256Verifier(%s) expected an error", hash)
+		}
+	}
+}
+
+func TestSha512Verifier(t *testing.T) {
+	const helloWorld = "309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"
+	tests := []struct {
+		name      string
+		write     []byte
+		wantError bool
+	}{
+		{name: "test okay hash", write: []byte("hello world")},
+		{name: "test wrong hash", write: []byte("HELLO WORLD"), wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := newSha512Verifier(helloWorld)
+			if err != nil {
+				t.Fatalf("newSha512Verifier() error = %v", err)
+			}
+			io.Copy(v, bytes.NewReader(tt.write))
+			if err := v.Verify(); (err != nil) != tt.wantError {
+				t.Errorf("newSha512Verifier().Write(%x).Verify() = %v, want %v", tt.write, err, tt.wantError)
+			}
+		})
+	}
+	if _, err := newSha512Verifier(helloWorld[:64]); err == nil {
+		t.Error("newSha512Verifier() accepted a sha256 sum")
+	}
+}
+
 func TestTrueVerifier(t *testing.T) {
 	tests := []struct {
 		name      string
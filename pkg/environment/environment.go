@@ -54,10 +54,36 @@ func newPaths(base string) Paths {
 // BasePath returns krew base directory.
 func (p Paths) BasePath() string { return p.base }
 
-// IndexPath returns the base directory where plugin index repository is cloned.
+// IndexBase returns the base directory where all plugin index repositories
+// are cloned.
+//
+// e.g. {IndexBase}/{index-name}
+func (p Paths) IndexBase() string { return filepath.Join(p.base, "index") }
+
+// IndexPath returns the directory where the named plugin index repository is
+// cloned.
 //
 // e.g. {IndexPath}/plugins/{plugin}.yaml
-func (p Paths) IndexPath() string { return filepath.Join(p.base, "index") }
+func (p Paths) IndexPath(name string) string { return filepath.Join(p.IndexBase(), name) }
+
+// ConfigPath returns the path of the krew configuration file.
+//
+// e.g. {BasePath}/config.yaml
+func (p Paths) ConfigPath() string { return filepath.Join(p.base, "config.yaml") }
+
+// DownloadCachePath returns the directory where verified plugin archives are
+// cached.
+//
+// e.g. {DownloadCachePath}/{sha256}
+func (p Paths) DownloadCachePath() string { return filepath.Join(p.base, "cache", "downloads") }
+
+// GitCABundlePath returns the file with the certificates that git trusts when
+// additional CA files are configured.
+//
+// e.g. {BasePath}/cache/git-ca-bundle.pem
+func (p Paths) GitCABundlePath() string {
+	return filepath.Join(p.base, "cache", "git-ca-bundle.pem")
+}
 
 // BinPath returns the path where plugin executable symbolic links are found.
 // This path should be added to $PATH in client machine.
@@ -69,11 +95,26 @@ func (p Paths) BinPath() string { return filepath.Join(p.base, "bin") }
 // not create a new directory on each call.
 func (p Paths) DownloadPath() string { return filepath.Join(p.tmp, "krew-downloads") }
 
+// DownloadPartialPath returns the directory where interrupted downloads are
+// kept until they are resumed.
+func (p Paths) DownloadPartialPath() string { return filepath.Join(p.DownloadPath(), ".partial") }
+
 // InstallPath returns the base directory for plugin installations.
 //
 // e.g. {InstallPath}/{plugin-name}
 func (p Paths) InstallPath() string { return filepath.Join(p.base, "store") }
 
+// InstallReceiptsPath returns the base directory where plugin receipts are
+// stored.
+//
+// e.g. {InstallReceiptsPath}/{plugin-name}.yaml
+func (p Paths) InstallReceiptsPath() string { return filepath.Join(p.base, "receipts") }
+
+// PluginInstallReceiptPath returns the path to the receipt of the plugin.
+func (p Paths) PluginInstallReceiptPath(plugin string) string {
+	return filepath.Join(p.InstallReceiptsPath(), plugin+".yaml")
+}
+
 // PluginInstallPath returns the path to install the plugin.
 //
 // e.g. {PluginInstallPath}/{version}/{..files..}
@@ -53,9 +53,27 @@ func TestPaths(t *testing.T) {
 	if got, expected := p.BinPath(), filepath.FromSlash("/foo/bin"); got != expected {
 		t.Fatalf("BinPath()=%s; expected=%s", got, expected)
 	}
-	if got, expected := p.IndexPath(), filepath.FromSlash("/foo/index"); got != expected {
+	if got, expected := p.ConfigPath(), filepath.FromSlash("/foo/config.yaml"); got != expected {
+		t.Fatalf("ConfigPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.DownloadCachePath(), filepath.FromSlash("/foo/cache/downloads"); got != expected {
+		t.Fatalf("DownloadCachePath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.GitCABundlePath(), filepath.FromSlash("/foo/cache/git-ca-bundle.pem"); got != expected {
+		t.Fatalf("GitCABundlePath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.IndexBase(), filepath.FromSlash("/foo/index"); got != expected {
+		t.Fatalf("IndexBase()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.IndexPath("default"), filepath.FromSlash("/foo/index/default"); got != expected {
 		t.Fatalf("IndexPath()=%s; expected=%s", got, expected)
 	}
+	if got, expected := p.InstallReceiptsPath(), filepath.FromSlash("/foo/receipts"); got != expected {
+		t.Fatalf("InstallReceiptsPath()=%s; expected=%s", got, expected)
+	}
+	if got, expected := p.PluginInstallReceiptPath("my-plugin"), filepath.FromSlash("/foo/receipts/my-plugin.yaml"); got != expected {
+		t.Fatalf("PluginInstallReceiptPath()=%s; expected=%s", got, expected)
+	}
 	if got, expected := p.InstallPath(), filepath.FromSlash("/foo/store"); got != expected {
 		t.Fatalf("InstallPath()=%s; expected=%s", got, expected)
 	}
@@ -68,6 +86,9 @@ func TestPaths(t *testing.T) {
 	if got := p.DownloadPath(); !strings.HasSuffix(got, "krew-downloads") {
 		t.Fatalf("DownloadPath()=%s; expected suffix 'krew-downloads'", got)
 	}
+	if got, expected := p.DownloadPartialPath(), filepath.Join(p.DownloadPath(), ".partial"); got != expected {
+		t.Fatalf("DownloadPartialPath()=%s; expected=%s", got, expected)
+	}
 }
 
 func TestGetExecutedVersion(t *testing.T) {
@@ -18,20 +18,182 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	osexec "os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/golang/glog"
 )
 
+// Options configures how git connects to remote repositories.
+type Options struct {
+	// CAFiles are PEM files with certificates that git trusts in addition
+	// to the system certificates.
+	CAFiles []string
+	// CABundle is the file where the system certificates and the CA files
+	// are written, because git only takes a single CA file.
+	CABundle string
+	// ClientCert and ClientKey are PEM files with a client certificate.
+	ClientCert string
+	ClientKey  string
+	// Proxy is the URL of the proxy for HTTP remotes.
+	Proxy string
+}
+
+var options Options
+
+// systemCAFiles are the places of the system certificates on common
+// platforms, in the order that crypto/x509 looks for them.
+var systemCAFiles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",                // Debian/Ubuntu/Gentoo etc.
+	"/etc/pki/tls/certs/ca-bundle.crt",                  // Fedora/RHEL 6
+	"/etc/ssl/ca-bundle.pem",                            // OpenSUSE
+	"/etc/pki/tls/cacert.pem",                           // OpenELEC
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", // CentOS/RHEL 7
+	"/etc/ssl/cert.pem",                                 // Alpine Linux, macOS
+}
+
+// caBundle records if the CA bundle of the options was written.
+var caBundle struct {
+	sync.Mutex
+	written bool
+}
+
+// SetOptions sets the options for all following git commands. If CA files
+// are set, the CA bundle for git is written before the first git command.
+func SetOptions(o Options) error {
+	if len(o.CAFiles) > 0 && o.CABundle == "" {
+		return fmt.Errorf("no CA bundle file for git given")
+	}
+	caBundle.Lock()
+	defer caBundle.Unlock()
+	options = o
+	caBundle.written = false
+	return nil
+}
+
+// ensureCABundle writes the CA bundle of the options once.
+func ensureCABundle() error {
+	if len(options.CAFiles) == 0 {
+		return nil
+	}
+	caBundle.Lock()
+	defer caBundle.Unlock()
+	if caBundle.written {
+		return nil
+	}
+	if err := writeCABundle(options.CABundle, options.CAFiles); err != nil {
+		return err
+	}
+	caBundle.written = true
+	return nil
+}
+
+// systemCAFile returns the file with the system certificates, or "" if it
+// is not found.
+func systemCAFile() string {
+	if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+		return f
+	}
+	for _, f := range systemCAFiles {
+		if _, err := os.Stat(f); err == nil {
+			return f
+		}
+	}
+	return ""
+}
+
+// writeCABundle writes the system certificates and the CA files to the
+// bundle file. The bundle is kept if it is newer than all files and has the
+// size of their content.
+func writeCABundle(bundle string, caFiles []string) error {
+	files := caFiles
+	if system := systemCAFile(); system != "" {
+		files = append([]string{system}, caFiles...)
+	} else {
+		glog.V(1).Infof("Failed to find the system certificates, git only trusts the configured CA files")
+	}
+	if caBundleUpToDate(bundle, files) {
+		glog.V(4).Infof("CA bundle %q is up to date", bundle)
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, f := range files {
+		pem, err := ioutil.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("could not read CA file, err: %v", err)
+		}
+		buf.Write(pem)
+		buf.WriteByte('\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(bundle), 0755); err != nil {
+		return fmt.Errorf("could not create CA bundle dir, err: %v", err)
+	}
+	// Other krew processes may use the bundle at the same time.
+	tmp, err := ioutil.TempFile(filepath.Dir(bundle), filepath.Base(bundle)+".tmp")
+	if err != nil {
+		return fmt.Errorf("could not create CA bundle, err: %v", err)
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), bundle)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("could not write CA bundle, err: %v", err)
+	}
+	return nil
+}
+
+// caBundleUpToDate checks if the bundle was written after the last change of
+// the files and has the size of their content, each followed by a newline.
+func caBundleUpToDate(bundle string, files []string) bool {
+	bi, err := os.Stat(bundle)
+	if err != nil {
+		return false
+	}
+	var size int64
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil || !fi.ModTime().Before(bi.ModTime()) {
+			return false
+		}
+		size += fi.Size() + 1
+	}
+	return bi.Size() == size
+}
+
+// configArgs returns the git arguments for the options.
+func (o Options) configArgs() []string {
+	var args []string
+	if len(o.CAFiles) > 0 {
+		args = append(args, "-c", "http.sslCAInfo="+o.CABundle)
+	}
+	if o.ClientCert != "" {
+		args = append(args, "-c", "http.sslCert="+o.ClientCert)
+	}
+	if o.ClientKey != "" {
+		args = append(args, "-c", "http.sslKey="+o.ClientKey)
+	}
+	if o.Proxy != "" {
+		args = append(args, "-c", "http.proxy="+o.Proxy)
+	}
+	return args
+}
+
 // EnsureCloned will clone into the destination path, otherwise will return no error.
 func EnsureCloned(uri, destinationPath string) error {
 	if ok, err := IsGitCloned(destinationPath); err != nil {
 		return err
 	} else if !ok {
-		return exec("", "clone", "-v", uri, destinationPath)
+		_, err = exec("", "clone", "-v", uri, destinationPath)
+		return err
 	}
 	return nil
 }
@@ -47,7 +209,8 @@ func IsGitCloned(gitPath string) (bool, error) {
 
 // update will fetch origin and set HEAD to origin/HEAD.
 func update(destinationPath string) error {
-	return exec(destinationPath, "pull", "--ff-only", "-v")
+	_, err := exec(destinationPath, "pull", "--ff-only", "-v")
+	return err
 }
 
 // EnsureUpdated will ensure the destination path exists and is up to date.
@@ -58,18 +221,42 @@ func EnsureUpdated(uri, destinationPath string) error {
 	return update(destinationPath)
 }
 
-func exec(pwd string, args ...string) error {
+// GetRemoteURL returns the URL of the "origin" remote of the git repository
+// at the given path.
+func GetRemoteURL(dir string) (string, error) {
+	return exec(dir, "config", "--get", "remote.origin.url")
+}
+
+// GetCurrentCommit returns the commit hash of HEAD of the git repository at
+// the given path.
+func GetCurrentCommit(dir string) (string, error) {
+	return exec(dir, "rev-parse", "HEAD")
+}
+
+// IsClean checks that the git repository at the given path has no changes
+// and no untracked files.
+func IsClean(dir string) (bool, error) {
+	out, err := exec(dir, "status", "--porcelain", "--untracked-files=all")
+	return out == "", err
+}
+
+// exec runs git in pwd and returns its standard output. The standard error is
+// only part of the error of a failed command.
+func exec(pwd string, args ...string) (string, error) {
+	if err := ensureCABundle(); err != nil {
+		return "", err
+	}
 	glog.V(4).Infof("Going to run git %s", strings.Join(args, " "))
-	cmd := osexec.Command("git", args...)
+	cmd := osexec.Command("git", append(options.configArgs(), args...)...)
 	cmd.Dir = pwd
-	buf := bytes.Buffer{}
-	var w io.Writer = &buf
+	var stdout, stderr bytes.Buffer
+	var outw, errw io.Writer = &stdout, &stderr
 	if glog.V(2) {
-		w = io.MultiWriter(w, os.Stderr)
+		outw, errw = io.MultiWriter(outw, os.Stderr), io.MultiWriter(errw, os.Stderr)
 	}
-	cmd.Stdout, cmd.Stderr = w, w
+	cmd.Stdout, cmd.Stderr = outw, errw
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("command err=%q output=%q", err, buf.String())
+		return "", fmt.Errorf("command err=%q output=%q", err, stderr.String())
 	}
-	return nil
+	return strings.TrimSpace(stdout.String()), nil
 }
@@ -0,0 +1,154 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptions_configArgs(t *testing.T) {
+	args := Options{
+		CAFiles:    []string{"/etc/ca.pem"},
+		CABundle:   "/krew/cache/git-ca-bundle.pem",
+		ClientCert: "/etc/cert.pem",
+		ClientKey:  "/etc/key.pem",
+		Proxy:      "http://proxy:3128",
+	}.configArgs()
+	want := []string{
+		"-c", "http.sslCAInfo=/krew/cache/git-ca-bundle.pem",
+		"-c", "http.sslCert=/etc/cert.pem",
+		"-c", "http.sslKey=/etc/key.pem",
+		"-c", "http.proxy=http://proxy:3128",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("configArgs() = %v, want %v", args, want)
+	}
+}
+
+func TestSetOptions_caBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitutil-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var files []string
+	for _, name := range []string{"system.pem", "a.pem", "b.pem"} {
+		f := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(f, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	defer os.Setenv("SSL_CERT_FILE", os.Getenv("SSL_CERT_FILE"))
+	os.Setenv("SSL_CERT_FILE", files[0])
+	defer SetOptions(Options{})
+
+	bundle := filepath.Join(dir, "cache", "git-ca-bundle.pem")
+	if err := SetOptions(Options{CAFiles: files[1:], CABundle: bundle}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(bundle); !os.IsNotExist(err) {
+		t.Fatalf("SetOptions() wrote the CA bundle before git runs, err: %v", err)
+	}
+	if _, err := exec(dir, "version"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "system.pem\na.pem\nb.pem\n"; got != want {
+		t.Errorf("CA bundle = %q, want %q", got, want)
+	}
+	if got := options.configArgs(); len(got) != 2 || got[1] != "http.sslCAInfo="+bundle {
+		t.Errorf("configArgs() = %v, want the CA bundle", got)
+	}
+
+	// A bundle that is newer than the CA files is kept.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(bundle, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetOptions(Options{CAFiles: files[1:], CABundle: bundle}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exec(dir, "version"); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(bundle); err != nil || !fi.ModTime().Equal(future) {
+		t.Errorf("up to date CA bundle was written again, err: %v", err)
+	}
+
+	// A changed CA file is added again.
+	if err := ioutil.WriteFile(files[1], []byte("new.pem"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := future.Add(time.Hour)
+	if err := os.Chtimes(files[1], later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetOptions(Options{CAFiles: files[1:], CABundle: bundle}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exec(dir, "version"); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(bundle); err != nil || string(content) != "system.pem\nnew.pem\nb.pem\n" {
+		t.Errorf("CA bundle = %q, %v after a CA file changed", content, err)
+	}
+
+	if err := SetOptions(Options{CAFiles: []string{filepath.Join(dir, "missing.pem")}, CABundle: bundle}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exec(dir, "version"); err == nil {
+		t.Error("exec() expected an error for a missing CA file")
+	}
+	if err := SetOptions(Options{CAFiles: files[1:]}); err == nil {
+		t.Error("SetOptions() expected an error without a CA bundle file")
+	}
+}
+
+func Test_exec_stdoutOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitutil-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := exec(dir, "init"); err != nil {
+		t.Fatal(err)
+	}
+	// git prints the branch switch to stderr.
+	if out, err := exec(dir, "checkout", "-b", "foo"); err != nil {
+		t.Fatal(err)
+	} else if out != "" {
+		t.Errorf("exec() = %q, want no stderr in the output", out)
+	}
+	if out, err := exec(dir, "symbolic-ref", "--short", "HEAD"); err != nil {
+		t.Fatal(err)
+	} else if out != "foo" {
+		t.Errorf("exec() = %q, want %q", out, "foo")
+	}
+	if _, err := exec(dir, "rev-parse", "--verify", "missing"); err == nil {
+		t.Error("exec() expected an error")
+	} else if !strings.Contains(err.Error(), "fatal") {
+		t.Errorf("exec() error = %v, want the stderr of git", err)
+	}
+}
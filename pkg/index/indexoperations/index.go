@@ -0,0 +1,147 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package indexoperations manages the plugin index repositories that are
+// cloned under the krew index directory.
+package indexoperations
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/GoogleContainerTools/krew/pkg/environment"
+	"github.com/GoogleContainerTools/krew/pkg/gitutil"
+	"github.com/GoogleContainerTools/krew/pkg/index"
+
+	"github.com/golang/glog"
+)
+
+// DefaultIndexName is the name of the upstream krew index.
+const DefaultIndexName = "default"
+
+// Index describes a plugin index repository.
+type Index struct {
+	Name string
+	URL  string
+}
+
+// IsValidIndexName checks if the index name is safe to use as a directory name.
+func IsValidIndexName(name string) bool {
+	return index.IsSafePluginName(name)
+}
+
+// ListIndexes returns all indexes cloned under the index base directory,
+// sorted by name.
+func ListIndexes(paths environment.Paths) ([]Index, error) {
+	dirs, err := ioutil.ReadDir(paths.IndexBase())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read index base directory, err: %v", err)
+	}
+
+	var indexes []Index
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		indexPath := paths.IndexPath(dir.Name())
+		if ok, err := gitutil.IsGitCloned(indexPath); err != nil {
+			return nil, err
+		} else if !ok {
+			glog.V(2).Infof("Skipping %q, it is not a git repository", indexPath)
+			continue
+		}
+		url, err := gitutil.GetRemoteURL(indexPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the remote URL of index %q, err: %v", dir.Name(), err)
+		}
+		indexes = append(indexes, Index{Name: dir.Name(), URL: url})
+	}
+	return indexes, nil
+}
+
+// AddIndex clones a new index under the given name.
+func AddIndex(paths environment.Paths, name, url string) error {
+	if !IsValidIndexName(name) {
+		return fmt.Errorf("index name %q is not allowed", name)
+	}
+	indexPath := paths.IndexPath(name)
+	if _, err := os.Stat(indexPath); err == nil {
+		return fmt.Errorf("index %q already exists", name)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check for an existing index, err: %v", err)
+	}
+	return gitutil.EnsureCloned(url, indexPath)
+}
+
+// DeleteIndex removes the clone of the named index.
+func DeleteIndex(paths environment.Paths, name string) error {
+	if !IsValidIndexName(name) {
+		return fmt.Errorf("index name %q is not allowed", name)
+	}
+	indexPath := paths.IndexPath(name)
+	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+		return fmt.Errorf("index %q does not exist", name)
+	} else if err != nil {
+		return fmt.Errorf("failed to read index %q, err: %v", name, err)
+	}
+	glog.V(2).Infof("Deleting index directory %q", indexPath)
+	return os.RemoveAll(indexPath)
+}
+
+// MigrateLegacyIndex moves an index cloned directly into the index base
+// directory, as older krew versions did, to the default index directory.
+func MigrateLegacyIndex(paths environment.Paths) error {
+	ok, err := gitutil.IsGitCloned(paths.IndexBase())
+	if err != nil {
+		return fmt.Errorf("failed to check for a legacy index, err: %v", err)
+	}
+	if !ok {
+		return nil
+	}
+	glog.V(1).Infof("Migrating legacy index at %q", paths.IndexBase())
+	tmp := paths.IndexBase() + ".migrating"
+	if err := os.Rename(paths.IndexBase(), tmp); err != nil {
+		return fmt.Errorf("failed to move legacy index, err: %v", err)
+	}
+	if err := os.MkdirAll(paths.IndexBase(), 0755); err != nil {
+		return fmt.Errorf("failed to create index base directory, err: %v", err)
+	}
+	if err := os.Rename(tmp, paths.IndexPath(DefaultIndexName)); err != nil {
+		return fmt.Errorf("failed to move legacy index to %q, err: %v", paths.IndexPath(DefaultIndexName), err)
+	}
+	return nil
+}
+
+// CanonicalPluginName returns the name a plugin is addressed with. Plugins of
+// the default index are addressed by their name alone, others as
+// "<index>/<plugin>".
+func CanonicalPluginName(indexName, plugin string) string {
+	if indexName == DefaultIndexName || indexName == "" {
+		return plugin
+	}
+	return indexName + "/" + plugin
+}
+
+// ParsePluginName splits a plugin name given as "<index>/<plugin>" into its
+// index and plugin names. Names without an index refer to the default index.
+func ParsePluginName(name string) (indexName, plugin string) {
+	if i := strings.Index(name, "/"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return DefaultIndexName, name
+}
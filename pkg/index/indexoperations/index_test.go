@@ -0,0 +1,151 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexoperations
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleContainerTools/krew/pkg/environment"
+)
+
+func testPaths(t *testing.T) (environment.Paths, func()) {
+	tempDir, err := ioutil.TempDir("", "krew-indexoperations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("KREW_ROOT", tempDir)
+	paths := environment.MustGetKrewPaths()
+	os.Unsetenv("KREW_ROOT")
+	return paths, func() { os.RemoveAll(tempDir) }
+}
+
+func initGitRepo(t *testing.T, dir, remote string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"init"}, {"remote", "add", "origin", remote}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v, output: %s", args, err, out)
+		}
+	}
+}
+
+func TestListIndexes(t *testing.T) {
+	paths, cleanup := testPaths(t)
+	defer cleanup()
+
+	initGitRepo(t, paths.IndexPath("foo"), "https://example.com/foo.git")
+	initGitRepo(t, paths.IndexPath("bar"), "https://example.com/bar.git")
+	if err := os.MkdirAll(paths.IndexPath("not-a-repo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ListIndexes(paths)
+	if err != nil {
+		t.Fatalf("ListIndexes() error = %v", err)
+	}
+	want := []Index{
+		{Name: "bar", URL: "https://example.com/bar.git"},
+		{Name: "foo", URL: "https://example.com/foo.git"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListIndexes() = %v, want %v", got, want)
+	}
+}
+
+func TestDeleteIndex(t *testing.T) {
+	paths, cleanup := testPaths(t)
+	defer cleanup()
+
+	initGitRepo(t, paths.IndexPath("foo"), "https://example.com/foo.git")
+	if err := DeleteIndex(paths, "foo"); err != nil {
+		t.Fatalf("DeleteIndex() error = %v", err)
+	}
+	if _, err := os.Stat(paths.IndexPath("foo")); !os.IsNotExist(err) {
+		t.Errorf("DeleteIndex() did not remove the index directory, err: %v", err)
+	}
+	if err := DeleteIndex(paths, "foo"); err == nil {
+		t.Errorf("DeleteIndex() of a missing index expected an error")
+	}
+	if err := DeleteIndex(paths, "../foo"); err == nil {
+		t.Errorf("DeleteIndex() with an unsafe name expected an error")
+	}
+}
+
+func TestAddIndex_invalid(t *testing.T) {
+	paths, cleanup := testPaths(t)
+	defer cleanup()
+
+	if err := AddIndex(paths, "../foo", "https://example.com/foo.git"); err == nil {
+		t.Errorf("AddIndex() with an unsafe name expected an error")
+	}
+	initGitRepo(t, paths.IndexPath("foo"), "https://example.com/foo.git")
+	if err := AddIndex(paths, "foo", "https://example.com/foo.git"); err == nil {
+		t.Errorf("AddIndex() of an existing index expected an error")
+	}
+}
+
+func TestMigrateLegacyIndex(t *testing.T) {
+	paths, cleanup := testPaths(t)
+	defer cleanup()
+
+	initGitRepo(t, paths.IndexBase(), "https://example.com/krew-index.git")
+	if err := MigrateLegacyIndex(paths); err != nil {
+		t.Fatalf("MigrateLegacyIndex() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(paths.IndexPath(DefaultIndexName), ".git")); err != nil {
+		t.Fatalf("MigrateLegacyIndex() did not move the index, err: %v", err)
+	}
+	// A second run finds nothing to migrate.
+	if err := MigrateLegacyIndex(paths); err != nil {
+		t.Fatalf("MigrateLegacyIndex() error = %v", err)
+	}
+}
+
+func TestParsePluginName(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantIndex  string
+		wantPlugin string
+	}{
+		{"foo", DefaultIndexName, "foo"},
+		{"custom/foo", "custom", "foo"},
+		{"default/foo", DefaultIndexName, "foo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			gotIndex, gotPlugin := ParsePluginName(tt.in)
+			if gotIndex != tt.wantIndex || gotPlugin != tt.wantPlugin {
+				t.Errorf("ParsePluginName(%q) = (%q, %q), want (%q, %q)", tt.in, gotIndex, gotPlugin, tt.wantIndex, tt.wantPlugin)
+			}
+		})
+	}
+}
+
+func TestCanonicalPluginName(t *testing.T) {
+	if got := CanonicalPluginName(DefaultIndexName, "foo"); got != "foo" {
+		t.Errorf("CanonicalPluginName(default, foo) = %q, want %q", got, "foo")
+	}
+	if got := CanonicalPluginName("custom", "foo"); got != "custom/foo" {
+		t.Errorf("CanonicalPluginName(custom, foo) = %q, want %q", got, "custom/foo")
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexscanner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/krew/pkg/gitutil"
+	"github.com/GoogleContainerTools/krew/pkg/index"
+	"github.com/GoogleContainerTools/krew/pkg/version"
+
+	"github.com/golang/glog"
+)
+
+// cacheFormat must be changed whenever the layout of the cache or the
+// meaning of its content changes, so that old caches are rebuilt. Caches are
+// also rebuilt when krew is built from another commit, because decoding and
+// validation of manifests may have changed.
+const cacheFormat = "3"
+
+// cacheFileName is the name of the index cache. It is kept in the .git
+// directory of the clone so that it never shows up as a change in the index
+// and is removed together with the clone.
+const cacheFileName = "krew-index-cache.json"
+
+// indexCache is a snapshot of the parsed index at one commit, made by the krew
+// build from KrewCommit. HeadState is the headState of the clone when the
+// cache was written.
+type indexCache struct {
+	Format     string                `json:"format"`
+	APIVersion string                `json:"apiVersion"`
+	KrewCommit string                `json:"krewCommit"`
+	Commit     string                `json:"commit"`
+	HeadState  string                `json:"headState,omitempty"`
+	Plugins    index.PluginList      `json:"plugins"`
+	Errors     []cachedManifestError `json:"errors,omitempty"`
+}
+
+type cachedManifestError struct {
+	File string `json:"file"`
+	Err  string `json:"err"`
+}
+
+// UpdateCache parses the index and replaces its cache. It is a no-op for
+// indexes that are not git clones.
+func UpdateCache(indexDir string) error {
+	indexDir, err := filepath.EvalSymlinks(indexDir)
+	if err != nil {
+		return err
+	}
+	state := headState(indexDir)
+	commit, err := currentCommit(indexDir)
+	if err != nil || commit == "" {
+		return err
+	}
+	result, err := scanPluginList(indexDir)
+	if err != nil {
+		return err
+	}
+	return writeCache(indexDir, commit, state, result)
+}
+
+// loadCache returns the cached result of the index. The cache is used without
+// running git if the headState of the clone did not change since the cache
+// was written. Otherwise it is used if it was made at the checked out commit
+// and the clone has no changes. commit is the checked out commit if git was
+// run, it is empty if the index must not be cached.
+func loadCache(indexDir, state string) (result LoadResult, ok bool, commit string, err error) {
+	c, cached := readCache(indexDir)
+	if cached && state != "" && c.HeadState == state {
+		glog.V(4).Infof("Index %s did not change since the cache was written", indexDir)
+		return c.result(), true, "", nil
+	}
+	commit, err = currentCommit(indexDir)
+	if err != nil || commit == "" {
+		return LoadResult{}, false, "", err
+	}
+	if !cached || c.Commit != commit {
+		glog.V(3).Infof("Index cache of %s is stale", indexDir)
+		return LoadResult{}, false, commit, nil
+	}
+	result = c.result()
+	if state != c.HeadState {
+		// The next load does not need git.
+		if err := writeCache(indexDir, commit, state, result); err != nil {
+			glog.V(2).Infof("Failed to update the index cache, err: %v", err)
+		}
+	}
+	return result, true, commit, nil
+}
+
+// headState returns a fingerprint of the checked out commit that is read
+// without running git: the content of .git/HEAD, the content and mtime of the
+// ref that HEAD points to, and the mtime of the plugins directory, which
+// changes when manifests are added or removed. It returns an empty string if
+// the state can't be read, then git is asked for the commit.
+func headState(indexDir string) string {
+	gitDir := filepath.Join(indexDir, ".git")
+	head, err := ioutil.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	state := strings.TrimSpace(string(head))
+	if ref := strings.TrimPrefix(state, "ref: "); ref != state {
+		refFile := filepath.Join(gitDir, filepath.FromSlash(ref))
+		content, err := ioutil.ReadFile(refFile)
+		if os.IsNotExist(err) {
+			// The ref is packed.
+			refFile = filepath.Join(gitDir, "packed-refs")
+		} else if err != nil {
+			return ""
+		}
+		fi, err := os.Stat(refFile)
+		if err != nil {
+			return ""
+		}
+		state += fmt.Sprintf("\x00%s\x00%d\x00%d", strings.TrimSpace(string(content)), fi.Size(), fi.ModTime().UnixNano())
+	}
+	fi, err := os.Stat(filepath.Join(indexDir, "plugins"))
+	if err != nil {
+		return ""
+	}
+	return state + fmt.Sprintf("\x00%d", fi.ModTime().UnixNano())
+}
+
+// currentCommit returns the checked out commit of the index, or an empty
+// string if the index is not a git clone or has changes that are not
+// committed. Such indexes are not cached.
+func currentCommit(indexDir string) (string, error) {
+	if ok, err := gitutil.IsGitCloned(indexDir); err != nil {
+		return "", err
+	} else if !ok {
+		return "", nil
+	}
+	commit, err := gitutil.GetCurrentCommit(indexDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the index commit, err: %v", err)
+	}
+	clean, err := gitutil.IsClean(indexDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the status of the index, err: %v", err)
+	}
+	if !clean {
+		glog.V(2).Infof("Not caching %s, it has uncommitted changes", indexDir)
+		return "", nil
+	}
+	return commit, nil
+}
+
+func cachePath(indexDir string) string {
+	return filepath.Join(indexDir, ".git", cacheFileName)
+}
+
+// readCache returns the cache of the index if it was written by this krew
+// build.
+func readCache(indexDir string) (indexCache, bool) {
+	var c indexCache
+	raw, err := ioutil.ReadFile(cachePath(indexDir))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.V(2).Infof("Failed to read the index cache, err: %v", err)
+		}
+		return c, false
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		glog.V(2).Infof("Ignoring corrupt index cache, err: %v", err)
+		return c, false
+	}
+	if c.Format != cacheFormat || c.APIVersion != index.CurrentAPIVersion || c.KrewCommit != version.GitCommit() {
+		glog.V(3).Infof("Index cache of %s is from another krew build", indexDir)
+		return c, false
+	}
+	return c, true
+}
+
+// result returns the cached plugins and manifest errors.
+func (c indexCache) result() LoadResult {
+	result := LoadResult{Plugins: c.Plugins}
+	for _, e := range c.Errors {
+		result.Errors = append(result.Errors, ManifestError{File: e.File, Err: errors.New(e.Err)})
+	}
+	return result
+}
+
+// writeCache atomically replaces the cache of the index.
+func writeCache(indexDir, commit, state string, result LoadResult) error {
+	c := indexCache{
+		Format:     cacheFormat,
+		APIVersion: index.CurrentAPIVersion,
+		KrewCommit: version.GitCommit(),
+		Commit:     commit,
+		HeadState:  state,
+		Plugins:    result.Plugins,
+	}
+	for _, e := range result.Errors {
+		c.Errors = append(c.Errors, cachedManifestError{File: e.File, Err: e.Err.Error()})
+	}
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode the index cache, err: %v", err)
+	}
+
+	dest := cachePath(indexDir)
+	f, err := ioutil.TempFile(filepath.Dir(dest), cacheFileName)
+	if err != nil {
+		return fmt.Errorf("failed to create the index cache, err: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(raw); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write the index cache, err: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write the index cache, err: %v", err)
+	}
+	glog.V(3).Infof("Writing index cache %q for commit %s", dest, commit)
+	return os.Rename(f.Name(), dest)
+}
@@ -0,0 +1,205 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexscanner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func git(t *testing.T, dir string, args ...string) {
+	args = append([]string{"-c", "user.name=krew", "-c", "user.email=krew@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v, output: %s", args, err, out)
+	}
+}
+
+func copyTestPlugin(t *testing.T, name, indexDir string) {
+	raw, err := ioutil.ReadFile(filepath.Join(testdataPath(t), "testindex", "plugins", name+".yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(indexDir, "plugins", name+".yaml"), raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPluginListFromFS_cache(t *testing.T) {
+	indexDir, err := ioutil.TempDir("", "krew-index-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(indexDir)
+	if err := os.MkdirAll(filepath.Join(indexDir, "plugins"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	copyTestPlugin(t, "foo", indexDir)
+	copyTestPlugin(t, "badplugin", indexDir)
+	git(t, indexDir, "init")
+	git(t, indexDir, "add", ".")
+	git(t, indexDir, "commit", "-m", "first")
+
+	got, err := LoadPluginListFromFS(indexDir)
+	if err != nil {
+		t.Fatalf("LoadPluginListFromFS() error = %v", err)
+	}
+	if len(got.Plugins.Items) != 1 || len(got.Errors) != 1 {
+		t.Fatalf("LoadPluginListFromFS() = %d plugins, %d errors; want 1, 1", len(got.Plugins.Items), len(got.Errors))
+	}
+	if _, err := os.Stat(cachePath(indexDir)); err != nil {
+		t.Fatalf("LoadPluginListFromFS() did not write the cache, err: %v", err)
+	}
+
+	got, err = LoadPluginListFromFS(indexDir)
+	if err != nil {
+		t.Fatalf("LoadPluginListFromFS() error = %v", err)
+	}
+	if len(got.Plugins.Items) != 1 || len(got.Errors) != 1 {
+		t.Fatalf("LoadPluginListFromFS() from cache = %d plugins, %d errors; want 1, 1", len(got.Plugins.Items), len(got.Errors))
+	}
+
+	// Changes that are not committed are seen, the cache is not used.
+	copyTestPlugin(t, "bar", indexDir)
+	got, err = LoadPluginListFromFS(indexDir)
+	if err != nil {
+		t.Fatalf("LoadPluginListFromFS() error = %v", err)
+	}
+	if len(got.Plugins.Items) != 2 {
+		t.Fatalf("LoadPluginListFromFS() with uncommitted changes = %d plugins; want 2", len(got.Plugins.Items))
+	}
+
+	// A new commit makes the cache stale.
+	git(t, indexDir, "add", ".")
+	git(t, indexDir, "commit", "-m", "second")
+	if c, ok := readCache(indexDir); !ok || c.Commit == headCommit(t, indexDir) || c.HeadState == headState(indexDir) {
+		t.Fatal("cache of the previous commit is not stale")
+	}
+	got, err = LoadPluginListFromFS(indexDir)
+	if err != nil {
+		t.Fatalf("LoadPluginListFromFS() error = %v", err)
+	}
+	if len(got.Plugins.Items) != 2 {
+		t.Fatalf("LoadPluginListFromFS() after commit = %d plugins; want 2", len(got.Plugins.Items))
+	}
+}
+
+func headCommit(t *testing.T, dir string) string {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func Test_readCache_otherKrewBuild(t *testing.T) {
+	indexDir, err := ioutil.TempDir("", "krew-index-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(indexDir)
+	if err := os.MkdirAll(filepath.Join(indexDir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCache(indexDir, "abc", "", LoadResult{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readCache(indexDir); !ok {
+		t.Fatal("readCache() did not use the fresh cache")
+	}
+
+	raw, err := ioutil.ReadFile(cachePath(indexDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c map[string]interface{}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		t.Fatal(err)
+	}
+	c["krewCommit"] = "other"
+	if raw, err = json.Marshal(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cachePath(indexDir), raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readCache(indexDir); ok {
+		t.Fatal("readCache() used the cache of another krew build")
+	}
+}
+
+func TestUpdateCache_notGitRepo(t *testing.T) {
+	indexDir := filepath.Join(testdataPath(t), "testindex")
+	if err := UpdateCache(indexDir); err != nil {
+		t.Fatalf("UpdateCache() error = %v", err)
+	}
+	if _, err := os.Stat(cachePath(indexDir)); !os.IsNotExist(err) {
+		t.Fatalf("UpdateCache() wrote a cache for a directory that is not a git clone")
+	}
+}
+
+func TestLoadPluginListFromFS_cacheWithoutGit(t *testing.T) {
+	indexDir, err := ioutil.TempDir("", "krew-index-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(indexDir)
+	if err := os.MkdirAll(filepath.Join(indexDir, "plugins"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	copyTestPlugin(t, "foo", indexDir)
+	git(t, indexDir, "init")
+	git(t, indexDir, "add", ".")
+	git(t, indexDir, "commit", "-m", "first")
+	if _, err := LoadPluginListFromFS(indexDir); err != nil {
+		t.Fatal(err)
+	}
+	c, ok := readCache(indexDir)
+	if !ok || c.HeadState == "" || c.HeadState != headState(indexDir) {
+		t.Fatalf("cache head state = %q, want %q", c.HeadState, headState(indexDir))
+	}
+
+	// The clone is read from the cache without asking git for its status
+	// while the head state does not change, so a manifest that is changed
+	// in place is not seen.
+	if err := ioutil.WriteFile(filepath.Join(indexDir, "plugins", "foo.yaml"), []byte("invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadPluginListFromFS(indexDir)
+	if err != nil {
+		t.Fatalf("LoadPluginListFromFS() error = %v", err)
+	}
+	if len(got.Plugins.Items) != 1 || len(got.Errors) != 0 {
+		t.Fatalf("LoadPluginListFromFS() from cache = %d plugins, %d errors; want 1, 0", len(got.Plugins.Items), len(got.Errors))
+	}
+
+	// A new commit changes the head state, git is asked again.
+	git(t, indexDir, "commit", "-am", "second")
+	got, err = LoadPluginListFromFS(indexDir)
+	if err != nil {
+		t.Fatalf("LoadPluginListFromFS() error = %v", err)
+	}
+	if len(got.Plugins.Items) != 0 || len(got.Errors) != 1 {
+		t.Fatalf("LoadPluginListFromFS() after commit = %d plugins, %d errors; want 0, 1", len(got.Plugins.Items), len(got.Errors))
+	}
+}
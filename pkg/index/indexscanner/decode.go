@@ -0,0 +1,195 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexscanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/krew/pkg/index"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Converter decodes a manifest of one apiVersion and converts it to the
+// index.Plugin schema. The manifest is passed as raw YAML, so that errors can
+// point to lines in the original file.
+type Converter func(raw []byte) (index.Plugin, error)
+
+var converters = map[string]Converter{}
+
+// RegisterConverter makes manifests with the given apiVersion readable. It
+// panics if a converter for the apiVersion is already registered.
+func RegisterConverter(apiVersion string, c Converter) {
+	if _, ok := converters[apiVersion]; ok {
+		panic(fmt.Sprintf("converter for apiVersion %q is already registered", apiVersion))
+	}
+	converters[apiVersion] = c
+}
+
+// SupportedAPIVersions returns the sorted list of readable apiVersions.
+func SupportedAPIVersions() []string {
+	var versions []string
+	for v := range converters {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func init() {
+	RegisterConverter(index.CurrentAPIVersion, decodeCurrent)
+	RegisterConverter(index.APIGroup+"/v1alpha1", convertV1alpha1)
+}
+
+// checkKrewVersion checks that the running krew is new enough for a plugin,
+// it is replaced in tests.
+var checkKrewVersion = index.CheckKrewVersion
+
+// decodeCurrent strictly decodes a manifest of the current apiVersion.
+func decodeCurrent(raw []byte) (index.Plugin, error) {
+	var plugin index.Plugin
+	if err := decodeStrict(raw, &plugin); err != nil {
+		return plugin, newerKrewRequired(raw, err)
+	}
+	return plugin, nil
+}
+
+// newerKrewRequired returns a *index.KrewVersionError instead of the
+// decoding error if the manifest needs a newer krew. Such manifests may use
+// fields that this krew does not know yet, so minKrewVersion is read without
+// checking the other fields.
+func newerKrewRequired(raw []byte, decodeErr error) error {
+	jsonRaw, err := yaml.ToJSON(raw)
+	if err != nil {
+		return decodeErr
+	}
+	var manifest struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			MinKrewVersion string `json:"minKrewVersion"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(jsonRaw, &manifest); err != nil || manifest.Spec.MinKrewVersion == "" {
+		return decodeErr
+	}
+	plugin := index.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: manifest.Metadata.Name},
+		Spec:       index.PluginSpec{MinKrewVersion: manifest.Spec.MinKrewVersion},
+	}
+	if err, ok := checkKrewVersion(plugin).(*index.KrewVersionError); ok {
+		return err
+	}
+	return decodeErr
+}
+
+// convertV1alpha1 reads v1alpha1 manifests. Their schema is a subset of the
+// current one, so only the apiVersion changes.
+func convertV1alpha1(raw []byte) (index.Plugin, error) {
+	plugin, err := decodeCurrent(raw)
+	if err != nil {
+		return plugin, err
+	}
+	plugin.APIVersion = index.CurrentAPIVersion
+	return plugin, nil
+}
+
+// decodeManifest checks the apiVersion and kind of a manifest and converts it
+// with the registered converter.
+func decodeManifest(raw []byte) (index.Plugin, error) {
+	jsonRaw, err := yaml.ToJSON(raw)
+	if err != nil {
+		return index.Plugin{}, err
+	}
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(jsonRaw, &typeMeta); err != nil {
+		return index.Plugin{}, fmt.Errorf("failed to read apiVersion and kind, err: %v", err)
+	}
+	if typeMeta.Kind != index.PluginKind {
+		return index.Plugin{}, fmt.Errorf("unsupported kind %q, expected %q", typeMeta.Kind, index.PluginKind)
+	}
+	convert, ok := converters[typeMeta.APIVersion]
+	if !ok {
+		return index.Plugin{}, fmt.Errorf("unsupported apiVersion %q, supported versions are: %s",
+			typeMeta.APIVersion, strings.Join(SupportedAPIVersions(), ", "))
+	}
+	return convert(raw)
+}
+
+// DecodeError is returned when a manifest does not match its schema.
+type DecodeError struct {
+	// Line is the 1-based line in the manifest that caused the error, or 0 if
+	// it is unknown.
+	Line int
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return e.Err.Error()
+}
+
+var unknownFieldRegexp = regexp.MustCompile(`unknown field "(.*)"`)
+
+// decodeStrict decodes YAML into v and fails on fields that v does not have.
+func decodeStrict(raw []byte, v interface{}) error {
+	jsonRaw, err := yaml.ToJSON(raw)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonRaw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &DecodeError{Line: lineOfKey(raw, fieldOfError(err)), Err: err}
+	}
+	return nil
+}
+
+// fieldOfError returns the name of the field a JSON decoding error is about.
+func fieldOfError(err error) string {
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		field := typeErr.Field
+		return field[strings.LastIndex(field, ".")+1:]
+	}
+	if m := unknownFieldRegexp.FindStringSubmatch(err.Error()); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// lineOfKey returns the first line in the YAML document that defines key.
+func lineOfKey(raw []byte, key string) int {
+	if key == "" {
+		return 0
+	}
+	keyRegexp := regexp.MustCompile(`^\s*(-\s+)?["']?` + regexp.QuoteMeta(key) + `["']?\s*:`)
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for line := 1; scanner.Scan(); line++ {
+		if keyRegexp.MatchString(scanner.Text()) {
+			return line
+		}
+	}
+	return 0
+}
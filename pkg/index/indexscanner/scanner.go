@@ -15,53 +15,131 @@
 package indexscanner
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/GoogleContainerTools/krew/pkg/index"
 
 	"github.com/golang/glog"
-	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
-// LoadPluginListFromFS will parse and retrieve all plugin files.
-func LoadPluginListFromFS(indexDir string) (index.PluginList, error) {
-	var indexList index.PluginList
+// maxLoadWorkers bounds the number of manifests that are read concurrently.
+const maxLoadWorkers = 16
+
+// ManifestError describes a plugin manifest that could not be loaded.
+type ManifestError struct {
+	// File is the path of the manifest relative to the index directory.
+	File string
+	Err  error
+}
+
+func (e ManifestError) Error() string { return fmt.Sprintf("%s: %v", e.File, e.Err) }
+
+// LoadResult contains the plugins of an index together with the manifests
+// that could not be loaded.
+type LoadResult struct {
+	Plugins index.PluginList
+	Errors  []ManifestError
+}
+
+// LoadPluginListFromFS will parse and retrieve all plugin files. A manifest
+// that fails to load does not fail the whole index, it is reported in the
+// Errors of the result instead. For git clones, the result is read from the
+// index cache when it matches the checked out commit. Changes to manifests
+// that are not committed are only seen if they add or remove manifests or
+// the checked out commit changes.
+func LoadPluginListFromFS(indexDir string) (LoadResult, error) {
 	indexDir, err := filepath.EvalSymlinks(indexDir)
 	if err != nil {
-		return indexList, err
+		return LoadResult{}, err
+	}
+	state := headState(indexDir)
+	result, ok, commit, err := loadCache(indexDir, state)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	if ok {
+		glog.V(4).Infof("Read %d plugins from the cache of %s", len(result.Plugins.Items), indexDir)
+		for _, e := range result.Errors {
+			logManifestError(e)
+		}
+		return result, nil
+	}
+
+	result, err = scanPluginList(indexDir)
+	if err != nil {
+		return result, err
+	}
+	if commit != "" {
+		if err := writeCache(indexDir, commit, state, result); err != nil {
+			glog.V(2).Infof("Failed to write the index cache, err: %v", err)
+		}
 	}
+	return result, nil
+}
+
+// logManifestError logs a manifest that failed to load, the details are
+// shown with -v=1.
+func logManifestError(e ManifestError) {
+	glog.V(1).Infof("failed to load file %q, err: %v", e.File, e.Err)
+}
 
+// scanPluginList reads all plugin files of the index.
+func scanPluginList(indexDir string) (LoadResult, error) {
+	var result LoadResult
 	files, err := ioutil.ReadDir(filepath.Join(indexDir, "plugins"))
 	if err != nil {
-		return indexList, fmt.Errorf("failed to open index dir, err: %v", err)
+		return result, fmt.Errorf("failed to open index dir, err: %v", err)
 	}
 
+	var pluginNames []string
 	for _, f := range files {
-		if f.IsDir() {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".yaml" {
 			continue
 		}
+		pluginNames = append(pluginNames, strings.TrimSuffix(f.Name(), filepath.Ext(f.Name())))
+	}
+
+	plugins := make([]index.Plugin, len(pluginNames))
+	errs := make([]error, len(pluginNames))
+	jobs := make(chan int)
+	workers := maxLoadWorkers
+	if len(pluginNames) < workers {
+		workers = len(pluginNames)
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				plugins[i], errs[i] = LoadPluginFileFromFS(indexDir, pluginNames[i])
+			}
+		}()
+	}
+	for i := range pluginNames {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
 
-		pluginName := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
-		// TODO(lbb): Use go routines to speed up slow FS operations.
-		p, err := LoadPluginFileFromFS(indexDir, pluginName)
-		if err != nil {
-			// Index loading shouldn't fail because of one plugin.
-			// Show error instead.
-			glog.Errorf("failed to load file %q, err: %v", pluginName, err)
+	for i, name := range pluginNames {
+		if errs[i] != nil {
+			e := ManifestError{File: filepath.Join("plugins", name+".yaml"), Err: errs[i]}
+			logManifestError(e)
+			result.Errors = append(result.Errors, e)
 			continue
 		}
-		indexList.Items = append(indexList.Items, p)
+		result.Plugins.Items = append(result.Plugins.Items, plugins[i])
 	}
-	glog.V(4).Infof("Found %d plugins in dir %s", len(indexList.Items), indexDir)
+	glog.V(4).Infof("Found %d plugins in dir %s", len(result.Plugins.Items), indexDir)
 
-	return indexList, nil
+	return result, nil
 }
 
 // LoadPluginFileFromFS loads a plugins index file by its name. When plugin
@@ -77,7 +155,7 @@ func LoadPluginFileFromFS(indexDir, pluginName string) (index.Plugin, error) {
 		return index.Plugin{}, err
 	}
 	p, err := ReadPluginFile(filepath.Join(indexDir, pluginName+".yaml"))
-	if os.IsNotExist(err) {
+	if _, ok := err.(*index.KrewVersionError); ok || os.IsNotExist(err) {
 		return index.Plugin{}, err
 	} else if err != nil {
 		return index.Plugin{}, fmt.Errorf("failed to read the plugin file, err: %v", err)
@@ -87,7 +165,6 @@ func LoadPluginFileFromFS(indexDir, pluginName string) (index.Plugin, error) {
 
 // ReadPluginFile loads a file from the FS. When plugin file not found, it
 // returns an error that can be checked with os.IsNotExist.
-// TODO(lbb): Add object verification
 func ReadPluginFile(indexFilePath string) (index.Plugin, error) {
 	f, err := os.Open(indexFilePath)
 	if os.IsNotExist(err) {
@@ -95,23 +172,16 @@ func ReadPluginFile(indexFilePath string) (index.Plugin, error) {
 	} else if err != nil {
 		return index.Plugin{}, fmt.Errorf("failed to open index file, err: %v", err)
 	}
+	defer f.Close()
 	return DecodePluginFile(f)
 }
 
-// DecodePluginFile tries to decodes a plugin manifest from r.
+// DecodePluginFile tries to decodes a plugin manifest from r. The manifest
+// must have a supported apiVersion and must not contain unknown fields.
 func DecodePluginFile(r io.Reader) (index.Plugin, error) {
-	var plugin index.Plugin
 	raw, err := ioutil.ReadAll(r)
 	if err != nil {
-		return plugin, err
-	}
-	jsonRaw, err := yaml.ToJSON(raw)
-	if err != nil {
-		return plugin, err
+		return index.Plugin{}, err
 	}
-	decoder := json.NewDecoder(bytes.NewReader(jsonRaw))
-
-	// TODO(lbb): Enable strict visioning.
-	// decoder.DisallowUnknownFields()
-	return plugin, decoder.Decode(&plugin)
+	return decodeManifest(raw)
 }
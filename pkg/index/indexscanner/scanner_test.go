@@ -15,10 +15,15 @@
 package indexscanner
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/GoogleContainerTools/krew/pkg/index"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 )
@@ -43,22 +48,20 @@ func Test_readIndexFile(t *testing.T) {
 				"os": "macos",
 			},
 		},
-		/*
-			{
-				name: "read index file with unknown keys",
-				args: args{
-					indexFilePath: filepath.Join(testdataPath(t), "testindex", "plugins", "badplugin.yaml"),
-				},
-				wantErr: true,
+		{
+			name: "read index file with unknown keys",
+			args: args{
+				indexFilePath: filepath.Join(testdataPath(t), "testindex", "plugins", "badplugin.yaml"),
 			},
-			{
-				name: "read index file with unknown keys",
-				args: args{
-					indexFilePath: filepath.Join(testdataPath(t), "testindex", "plugins", "badplugin2.yaml"),
-				},
-				wantErr: true,
+			wantErr: true,
+		},
+		{
+			name: "read index file with unknown keys",
+			args: args{
+				indexFilePath: filepath.Join(testdataPath(t), "testindex", "plugins", "badplugin2.yaml"),
 			},
-		*/
+			wantErr: true,
+		},
 	}
 	neverMatch := labels.Set{}
 
@@ -112,10 +115,25 @@ func TestLoadIndexListFromFS(t *testing.T) {
 				t.Errorf("LoadPluginListFromFS() error = %v)", err)
 				return
 			}
-			if len(got.Items) != 2 {
-				t.Errorf("LoadPluginListFromFS() didn't read enough index files, got %d)", len(got.Items))
+			if len(got.Plugins.Items) != 2 {
+				t.Errorf("LoadPluginListFromFS() didn't read enough index files, got %d)", len(got.Plugins.Items))
 				return
 			}
+			if got.Plugins.Items[0].Name != "bar" || got.Plugins.Items[1].Name != "foo" {
+				t.Errorf("LoadPluginListFromFS() returned plugins out of order: %s, %s", got.Plugins.Items[0].Name, got.Plugins.Items[1].Name)
+			}
+			var gotErrFiles []string
+			for _, e := range got.Errors {
+				gotErrFiles = append(gotErrFiles, e.File)
+			}
+			wantErrFiles := []string{
+				filepath.Join("plugins", "badplugin.yaml"),
+				filepath.Join("plugins", "badplugin2.yaml"),
+				filepath.Join("plugins", "wrongname.yaml"),
+			}
+			if !reflect.DeepEqual(gotErrFiles, wantErrFiles) {
+				t.Errorf("LoadPluginListFromFS() errors = %v, want errors for %v", got.Errors, wantErrFiles)
+			}
 		})
 	}
 }
@@ -174,6 +192,130 @@ func TestLoadIndexFileFromFS(t *testing.T) {
 	}
 }
 
+func TestDecodePluginFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+		wantLine int
+	}{
+		{
+			name: "current version",
+			manifest: `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: foo
+spec:
+  shortDescription: short`,
+		},
+		{
+			name: "older version is converted",
+			manifest: `apiVersion: krew.googlecontainertools.github.com/v1alpha1
+kind: Plugin
+metadata:
+  name: foo`,
+		},
+		{
+			name: "unknown field",
+			manifest: `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: foo
+spec:
+  platforms:
+  - uri: https://example.com
+    sha265: deadbeef`,
+			wantErr:  `unknown field "sha265"`,
+			wantLine: 8,
+		},
+		{
+			name: "wrong type",
+			manifest: `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: foo
+spec:
+  platforms: foo`,
+			wantErr:  "cannot unmarshal",
+			wantLine: 6,
+		},
+		{
+			name: "wrong kind",
+			manifest: `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Deployment
+metadata:
+  name: foo`,
+			wantErr: `unsupported kind "Deployment"`,
+		},
+		{
+			name: "unsupported version",
+			manifest: `apiVersion: krew.googlecontainertools.github.com/v9
+kind: Plugin
+metadata:
+  name: foo`,
+			wantErr: `unsupported apiVersion "krew.googlecontainertools.github.com/v9"`,
+		},
+		{
+			name: "missing version",
+			manifest: `kind: Plugin
+metadata:
+  name: foo`,
+			wantErr: `unsupported apiVersion ""`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodePluginFile(strings.NewReader(tt.manifest))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("DecodePluginFile() error = %v", err)
+				}
+				if got.APIVersion != index.CurrentAPIVersion {
+					t.Errorf("DecodePluginFile() apiVersion = %q, want %q", got.APIVersion, index.CurrentAPIVersion)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("DecodePluginFile() error = %v, want error containing %q", err, tt.wantErr)
+			}
+			if tt.wantLine == 0 {
+				return
+			}
+			if decodeErr, ok := err.(*DecodeError); !ok || decodeErr.Line != tt.wantLine {
+				t.Errorf("DecodePluginFile() error = %#v, want line %d", err, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestDecodePluginFile_newerKrew(t *testing.T) {
+	orig := checkKrewVersion
+	defer func() { checkKrewVersion = orig }()
+	checkKrewVersion = func(plugin index.Plugin) error {
+		if plugin.Spec.MinKrewVersion == "v9.0.0" {
+			return &index.KrewVersionError{Plugin: plugin.Name, Required: "v9.0.0", Current: "v0.2.0"}
+		}
+		return nil
+	}
+
+	manifest := `apiVersion: krew.googlecontainertools.github.com/v1alpha2
+kind: Plugin
+metadata:
+  name: foo
+spec:
+  minKrewVersion: %s
+  someFutureField: true`
+	_, err := DecodePluginFile(strings.NewReader(fmt.Sprintf(manifest, "v9.0.0")))
+	if verr, ok := err.(*index.KrewVersionError); !ok || verr.Plugin != "foo" {
+		t.Errorf("DecodePluginFile() error = %v, want *index.KrewVersionError for plugin foo", err)
+	}
+	// The unknown field is reported if krew is new enough.
+	_, err = DecodePluginFile(strings.NewReader(fmt.Sprintf(manifest, "v0.1.0")))
+	if _, ok := err.(*DecodeError); !ok || !strings.Contains(err.Error(), `unknown field "someFutureField"`) {
+		t.Errorf("DecodePluginFile() error = %v, want the unknown field", err)
+	}
+}
+
 func testdataPath(t *testing.T) string {
 	pwd, err := filepath.Abs(".")
 	if err != nil {
@@ -0,0 +1,69 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/GoogleContainerTools/krew/pkg/semver"
+	"github.com/GoogleContainerTools/krew/pkg/version"
+	"github.com/golang/glog"
+)
+
+// krewGitTag returns the version of the running krew, it is replaced in tests.
+var krewGitTag = version.GitTag
+
+// krewPluginName is the name of the plugin of krew itself.
+const krewPluginName = "krew"
+
+// describeSuffixRegexp matches what "git describe --tags --dirty" appends to
+// the tag of builds that are not exactly on a tag.
+var describeSuffixRegexp = regexp.MustCompile(`(-\d+-g[0-9a-f]+)?(-dirty)?$`)
+
+// KrewVersionError is returned for plugins that need a newer krew.
+type KrewVersionError struct {
+	Plugin   string
+	Required string
+	Current  string
+}
+
+func (e *KrewVersionError) Error() string {
+	return fmt.Sprintf("plugin %q requires krew %s or newer, but this is krew %s. Upgrade krew first with \"kubectl plugin upgrade krew\"", e.Plugin, e.Required, e.Current)
+}
+
+// CheckKrewVersion checks that the running krew is new enough for the plugin.
+// It returns a *KrewVersionError if it is not. Krew builds without a version
+// tag are assumed to be new enough.
+func CheckKrewVersion(plugin Plugin) error {
+	if plugin.Spec.MinKrewVersion == "" || plugin.Name == krewPluginName {
+		// Upgrading krew must always be possible.
+		return nil
+	}
+	required, err := semver.Parse(plugin.Spec.MinKrewVersion)
+	if err != nil {
+		return fmt.Errorf("invalid minKrewVersion of plugin %q, err: %v", plugin.Name, err)
+	}
+	tag := krewGitTag()
+	current, err := semver.Parse(describeSuffixRegexp.ReplaceAllString(tag, ""))
+	if err != nil {
+		glog.V(2).Infof("Can't compare krew version %q with minKrewVersion of plugin %s, err: %v", tag, plugin.Name, err)
+		return nil
+	}
+	if semver.Less(current, required) {
+		return &KrewVersionError{Plugin: plugin.Name, Required: required.String(), Current: tag}
+	}
+	return nil
+}
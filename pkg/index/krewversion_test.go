@@ -0,0 +1,57 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckKrewVersion(t *testing.T) {
+	orig := krewGitTag
+	defer func() { krewGitTag = orig }()
+
+	tests := []struct {
+		name       string
+		plugin     string
+		minVersion string
+		krewTag    string
+		wantErr    bool
+	}{
+		{name: "no requirement", plugin: "foo", minVersion: "", krewTag: "v0.1.0", wantErr: false},
+		{name: "new enough", plugin: "foo", minVersion: "v0.2.0", krewTag: "v0.2.0", wantErr: false},
+		{name: "too old", plugin: "foo", minVersion: "v0.3.0", krewTag: "v0.2.1", wantErr: true},
+		{name: "describe output", plugin: "foo", minVersion: "v0.2.1", krewTag: "v0.2.1-3-g8d1f2a3-dirty", wantErr: false},
+		{name: "unknown version", plugin: "foo", minVersion: "v0.3.0", krewTag: "unknown", wantErr: false},
+		{name: "krew itself", plugin: "krew", minVersion: "v9.0.0", krewTag: "v0.2.1", wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			krewGitTag = func() string { return tt.krewTag }
+			plugin := Plugin{
+				ObjectMeta: metav1.ObjectMeta{Name: tt.plugin},
+				Spec:       PluginSpec{MinKrewVersion: tt.minVersion},
+			}
+			err := CheckKrewVersion(plugin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckKrewVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := err.(*KrewVersionError); err != nil && !ok {
+				t.Errorf("CheckKrewVersion() error = %T, want *KrewVersionError", err)
+			}
+		})
+	}
+}
@@ -15,9 +15,23 @@
 package index
 
 import (
+	"strings"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// APIGroup is the API group of krew manifests.
+	APIGroup = "krew.googlecontainertools.github.com"
+
+	// CurrentAPIVersion is the apiVersion of manifests that use the Plugin
+	// schema defined in this package.
+	CurrentAPIVersion = APIGroup + "/v1alpha2"
+
+	// PluginKind is the kind of plugin manifests.
+	PluginKind = "Plugin"
+)
+
 // Plugin is a top-level type.
 // TODO(lbb): Add deepcopy code generation.
 type Plugin struct {
@@ -29,12 +43,83 @@ type Plugin struct {
 
 // PluginSpec TODO(lbb)
 type PluginSpec struct {
+	// Version is the semantic version of the plugin, like v1.2.3.
 	Version          string `json:"version,omitempty"`
 	ShortDescription string `json:"shortDescription,omitempty"`
 	Description      string `json:"description,omitempty"`
 	Caveats          string `json:"caveats,omitempty"`
 
+	// Homepage is the URL of the plugin's website or repository.
+	Homepage string `json:"homepage,omitempty"`
+	// License is the SPDX license expression of the plugin, like "Apache-2.0".
+	License     string       `json:"license,omitempty"`
+	Maintainers []Maintainer `json:"maintainers,omitempty"`
+	// Tags are keywords that plugins can be searched by.
+	Tags []string `json:"tags,omitempty"`
+	// Categories group plugins when browsing the index.
+	Categories []string `json:"categories,omitempty"`
+
 	Platforms []Platform `json:"platforms,omitempty"`
+
+	// MinKrewVersion is the oldest krew version that can install the plugin.
+	// It is needed when the manifest uses features that older krew versions
+	// ignore.
+	MinKrewVersion string `json:"minKrewVersion,omitempty"`
+
+	// Dependencies are plugins that are installed before this plugin.
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+
+	// Deprecated plugins are hidden from search and should not be installed.
+	Deprecated bool `json:"deprecated,omitempty"`
+	// DeprecationMessage tells users why the plugin is deprecated.
+	DeprecationMessage string `json:"deprecationMessage,omitempty"`
+	// ReplacedBy names the plugin that replaces a deprecated plugin. Plugins
+	// from other indexes are referenced as "<index>/<plugin>".
+	ReplacedBy string `json:"replacedBy,omitempty"`
+
+	// PublicKey is the "ed25519:<base64 key>" key of the publisher. Every
+	// download, including HEAD, must have a detached signature at
+	// "<uri>.sig" that was made with this key.
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// Maintainer is a person or team that maintains a plugin.
+type Maintainer struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+// Dependency is a plugin that another plugin requires.
+type Dependency struct {
+	// Name of the plugin. Plugins from other indexes are referenced as
+	// "<index>/<plugin>", otherwise the plugin comes from the same index.
+	Name string `json:"name"`
+	// Version is an optional constraint on the version of the plugin, like
+	// ">=v1.2.0, <v2.0.0".
+	Version string `json:"version,omitempty"`
+}
+
+// IndexName returns the index named in the dependency, or an empty string if
+// the dependency comes from the same index as the plugin.
+func (d Dependency) IndexName() string {
+	indexName, _ := SplitPluginRef(d.Name)
+	return indexName
+}
+
+// PluginName returns the name of the plugin without the index.
+func (d Dependency) PluginName() string {
+	_, name := SplitPluginRef(d.Name)
+	return name
+}
+
+// SplitPluginRef splits a reference to a plugin in a manifest, like
+// "<index>/<plugin>" or "<plugin>", into the index and plugin name. The index
+// is empty if the reference does not name one.
+func SplitPluginRef(ref string) (indexName, name string) {
+	if i := strings.Index(ref, "/"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return "", ref
 }
 
 // Platform TODO(lbb)
@@ -42,6 +127,15 @@ type Platform struct {
 	Head   string `json:"head,omitempty"`
 	URI    string `json:"uri,omitempty"`
 	Sha256 string `json:"sha256,omitempty"`
+	// Sha512 can be given in addition to or instead of Sha256. Downloads
+	// without a Sha256 are not cached.
+	Sha512 string `json:"sha512,omitempty"`
+	// Mirrors are other URIs of the archive at URI. They are tried in order
+	// if the download from URI fails or does not match the checksums.
+	Mirrors []string `json:"mirrors,omitempty"`
+	// BareBinary marks the download as a single executable instead of an
+	// archive. It is saved under the last element of the URI path.
+	BareBinary bool `json:"bareBinary,omitempty"`
 
 	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 	Files    []FileOperation       `json:"files"`
@@ -49,7 +143,30 @@ type Platform struct {
 	// Bin specifies the path to the plugin executable.
 	// The path is relative to the root of the installation folder.
 	// The binary will be linked after all FileOperations are executed.
+	Bin string `json:"bin,omitempty"`
+
+	// Binaries lists the executables of plugins that provide more than one
+	// kubectl command. It can't be used together with Bin.
+	Binaries []Binary `json:"binaries,omitempty"`
+}
+
+// Binary is an executable of a plugin and the command it provides.
+type Binary struct {
+	// Bin is the path to the executable, relative to the root of the
+	// installation folder.
 	Bin string `json:"bin"`
+	// Name is the name of the command, the executable is linked as
+	// kubectl-<name>.
+	Name string `json:"name"`
+}
+
+// Executables returns the binaries to link for the platform. A platform with
+// a single Bin provides a command with the name of the plugin.
+func (p Platform) Executables(pluginName string) []Binary {
+	if len(p.Binaries) > 0 {
+		return p.Binaries
+	}
+	return []Binary{{Bin: p.Bin, Name: pluginName}}
 }
 
 // FileOperation TODO(lbb)
@@ -65,3 +182,22 @@ type PluginList struct {
 
 	Items []Plugin `json:"items"`
 }
+
+// Receipt describes a plugin installation. It holds the manifest the plugin
+// was installed from and where that manifest came from.
+type Receipt struct {
+	Plugin `json:",inline"`
+
+	Status ReceiptStatus `json:"status"`
+}
+
+// ReceiptStatus contains information about the installed plugin.
+type ReceiptStatus struct {
+	Source SourceIndex `json:"source"`
+}
+
+// SourceIndex contains information about the index a plugin was installed from.
+type SourceIndex struct {
+	// Name is the configured name of the index that a plugin was installed from.
+	Name string `json:"name"`
+}
@@ -15,13 +15,28 @@
 package index
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net/mail"
+	"net/url"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
+
+	"github.com/GoogleContainerTools/krew/pkg/semver"
+	"golang.org/x/crypto/ed25519"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 var (
 	safePluginRegexp = regexp.MustCompile(`^[\w-]+$`)
+	sha256Regexp     = regexp.MustCompile(`^[a-fA-F0-9]{64}$`)
+	sha512Regexp     = regexp.MustCompile(`^[a-fA-F0-9]{128}$`)
+	keywordRegexp    = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	// spdxIDRegexp matches SPDX license identifiers and LicenseRef- references.
+	spdxIDRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.-]*\+?$`)
 	// windowsForbidden is taken from  https://docs.microsoft.com/en-us/windows/desktop/FileIO/naming-a-file
 	windowsForbidden = []string{"CON", "PRN", "AUX", "NUL", "COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9", "LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9"}
 )
@@ -39,41 +54,257 @@ func IsSafePluginName(name string) bool {
 	return true
 }
 
-// Validate TODO(lbb)
+// Validate checks the plugin manifest for the plugin file with the given
+// name. All problems found are returned as an aggregate error.
 func (p Plugin) Validate(name string) error {
+	var errs []error
 	if !IsSafePluginName(name) {
-		return fmt.Errorf("the plugin name %q is not allowed, must match %q", name, safePluginRegexp.String())
+		errs = append(errs, fmt.Errorf("the plugin name %q is not allowed, must match %q", name, safePluginRegexp.String()))
 	}
 	if p.Name != name {
-		return fmt.Errorf("plugin should be named %q, not %q", name, p.Name)
+		errs = append(errs, fmt.Errorf("plugin should be named %q, not %q", name, p.Name))
+	}
+	if p.Spec.Version == "" {
+		errs = append(errs, fmt.Errorf("should have a version"))
+	} else if _, err := semver.Parse(p.Spec.Version); err != nil {
+		errs = append(errs, fmt.Errorf("version should be a semantic version like v1.2.3, err: %v", err))
+	}
+	if p.Spec.MinKrewVersion != "" {
+		if _, err := semver.Parse(p.Spec.MinKrewVersion); err != nil {
+			errs = append(errs, fmt.Errorf("minKrewVersion should be a semantic version, err: %v", err))
+		}
 	}
 	if p.Spec.ShortDescription == "" {
-		return fmt.Errorf("should have a short description")
+		errs = append(errs, fmt.Errorf("should have a short description"))
 	}
 	if len(p.Spec.Platforms) == 0 {
-		return fmt.Errorf("should have a platform specified")
+		errs = append(errs, fmt.Errorf("should have a platform specified"))
+	}
+	errs = append(errs, p.Spec.validateMetadata()...)
+	if p.Spec.PublicKey != "" && !isPublicKey(p.Spec.PublicKey) {
+		errs = append(errs, fmt.Errorf("publicKey %q should be an ed25519 key like \"ed25519:<base64 key>\"", p.Spec.PublicKey))
+	}
+	if !p.Spec.Deprecated && (p.Spec.DeprecationMessage != "" || p.Spec.ReplacedBy != "") {
+		errs = append(errs, fmt.Errorf("deprecationMessage and replacedBy are only allowed on deprecated plugins"))
+	}
+	if p.Spec.ReplacedBy != "" {
+		if err := validatePluginRef(p.Spec.ReplacedBy); err != nil {
+			errs = append(errs, fmt.Errorf("replacedBy: %v", err))
+		} else if _, replacement := SplitPluginRef(p.Spec.ReplacedBy); replacement == name {
+			errs = append(errs, fmt.Errorf("replacedBy: plugin can't replace itself"))
+		}
 	}
-	for _, pl := range p.Spec.Platforms {
+	for i, d := range p.Spec.Dependencies {
+		if err := d.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("dependencies[%d]: %v", i, err))
+		} else if d.PluginName() == name {
+			errs = append(errs, fmt.Errorf("dependencies[%d]: plugin can't depend on itself", i))
+		}
+	}
+	for i, pl := range p.Spec.Platforms {
 		if err := pl.Validate(); err != nil {
-			return fmt.Errorf("platform (%+v) is badly constructed, err: %v", pl, err)
+			agg, ok := err.(utilerrors.Aggregate)
+			if !ok {
+				agg = utilerrors.NewAggregate([]error{err})
+			}
+			for _, e := range utilerrors.Flatten(agg).Errors() {
+				errs = append(errs, fmt.Errorf("platforms[%d]: %v", i, e))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateMetadata checks the optional fields that describe the plugin.
+func (s PluginSpec) validateMetadata() []error {
+	var errs []error
+	if s.Homepage != "" {
+		if u, err := url.Parse(s.Homepage); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("homepage %q should be an http or https URL", s.Homepage))
+		}
+	}
+	if s.License != "" && !isSPDXExpression(s.License) {
+		errs = append(errs, fmt.Errorf("license %q should be an SPDX license expression, like \"Apache-2.0\" or \"MIT OR Apache-2.0\"", s.License))
+	}
+	for i, m := range s.Maintainers {
+		if strings.TrimSpace(m.Name) == "" {
+			errs = append(errs, fmt.Errorf("maintainers[%d]: should have a name", i))
+		}
+		if m.Email != "" {
+			if addr, err := mail.ParseAddress(m.Email); err != nil || addr.Address != m.Email {
+				errs = append(errs, fmt.Errorf("maintainers[%d]: email %q is not a valid address", i, m.Email))
+			}
+		}
+	}
+	for i, tag := range s.Tags {
+		if !keywordRegexp.MatchString(tag) {
+			errs = append(errs, fmt.Errorf("tags[%d]: %q should be lowercase words separated by dashes", i, tag))
+		}
+	}
+	for i, c := range s.Categories {
+		if !keywordRegexp.MatchString(c) {
+			errs = append(errs, fmt.Errorf("categories[%d]: %q should be lowercase words separated by dashes", i, c))
+		}
+	}
+	return errs
+}
+
+// isSPDXExpression checks the syntax of an SPDX license expression. It does
+// not check that the identifiers are on the SPDX license list.
+func isSPDXExpression(expr string) bool {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	depth := 0
+	// expectID is true when the next token must be an identifier or "(".
+	expectID := true
+	for _, tok := range strings.Fields(expr) {
+		switch {
+		case tok == "(":
+			if !expectID {
+				return false
+			}
+			depth++
+		case tok == ")":
+			if expectID || depth == 0 {
+				return false
+			}
+			depth--
+		case tok == "AND" || tok == "OR" || tok == "WITH":
+			if expectID {
+				return false
+			}
+			expectID = true
+		case spdxIDRegexp.MatchString(tok):
+			if !expectID {
+				return false
+			}
+			expectID = false
+		default:
+			return false
 		}
 	}
+	return !expectID && depth == 0
+}
+
+// Validate checks the dependency name and version constraint.
+func (d Dependency) Validate() error {
+	if err := validatePluginRef(d.Name); err != nil {
+		return err
+	}
+	if _, err := semver.ParseConstraint(d.Version); err != nil {
+		return err
+	}
 	return nil
 }
 
-// Validate TODO(lbb)
+// validatePluginRef checks a reference to another plugin, see SplitPluginRef.
+func validatePluginRef(ref string) error {
+	indexName, name := SplitPluginRef(ref)
+	if indexName != "" && !IsSafePluginName(indexName) {
+		return fmt.Errorf("the index name in %q is not allowed", ref)
+	}
+	if !IsSafePluginName(name) {
+		return fmt.Errorf("the plugin name %q is not allowed, must match %q", ref, safePluginRegexp.String())
+	}
+	return nil
+}
+
+// Validate checks the platform. All problems found are returned as an
+// aggregate error.
 func (p Platform) Validate() error {
-	if (p.Sha256 != "") != (p.URI != "") {
-		return fmt.Errorf("can't get version URI and sha have both to be set or unset")
+	var errs []error
+	if (p.Sha256 != "" || p.Sha512 != "") != (p.URI != "") {
+		errs = append(errs, fmt.Errorf("can't get version URI and sha have both to be set or unset"))
 	}
 	if p.Head == "" && p.URI == "" {
-		return fmt.Errorf("head or URI have to be set")
+		errs = append(errs, fmt.Errorf("head or URI have to be set"))
+	}
+	if p.Sha256 != "" && !sha256Regexp.MatchString(p.Sha256) {
+		errs = append(errs, fmt.Errorf("sha256 %q is not a hex encoded sha256 sum", p.Sha256))
+	}
+	if len(p.Mirrors) > 0 && p.URI == "" {
+		errs = append(errs, fmt.Errorf("mirrors can only be set with uri"))
+	}
+	for i, m := range p.Mirrors {
+		if u, err := url.Parse(m); err != nil || u.Scheme == "" {
+			errs = append(errs, fmt.Errorf("mirrors[%d]: %q is not a URI", i, m))
+		}
+	}
+	if p.Sha512 != "" && !sha512Regexp.MatchString(p.Sha512) {
+		errs = append(errs, fmt.Errorf("sha512 %q is not a hex encoded sha512 sum", p.Sha512))
+	}
+	if _, err := metav1.LabelSelectorAsSelector(p.Selector); err != nil {
+		errs = append(errs, fmt.Errorf("invalid selector, err: %v", err))
+	}
+	switch {
+	case p.Bin != "" && len(p.Binaries) > 0:
+		errs = append(errs, fmt.Errorf("bin and binaries can't both be set"))
+	case p.Bin == "" && len(p.Binaries) == 0:
+		errs = append(errs, fmt.Errorf("bin has to be set"))
 	}
-	if p.Bin == "" {
-		return fmt.Errorf("bin has to be set")
+	if p.Bin != "" && (isAbsPath(p.Bin) || hasParentRef(p.Bin)) {
+		errs = append(errs, fmt.Errorf("bin %q must be a relative path inside the installation directory", p.Bin))
+	}
+	names := make(map[string]bool)
+	for i, b := range p.Binaries {
+		if b.Bin == "" {
+			errs = append(errs, fmt.Errorf("binaries[%d]: bin has to be set", i))
+		} else if isAbsPath(b.Bin) || hasParentRef(b.Bin) {
+			errs = append(errs, fmt.Errorf("binaries[%d]: bin %q must be a relative path inside the installation directory", i, b.Bin))
+		}
+		if !IsSafePluginName(b.Name) {
+			errs = append(errs, fmt.Errorf("binaries[%d]: the name %q is not allowed, must match %q", i, b.Name, safePluginRegexp.String()))
+		} else if names[b.Name] {
+			errs = append(errs, fmt.Errorf("binaries[%d]: the name %q is used more than once", i, b.Name))
+		}
+		names[b.Name] = true
 	}
 	if len(p.Files) == 0 {
-		return fmt.Errorf("can't have a plugin without specifying file operations")
+		errs = append(errs, fmt.Errorf("can't have a plugin without specifying file operations"))
 	}
-	return nil
+	for i, fo := range p.Files {
+		if isAbsPath(fo.From) {
+			errs = append(errs, fmt.Errorf("files[%d]: from %q must be relative to the archive root", i, fo.From))
+		} else if hasParentRef(fo.From) {
+			errs = append(errs, fmt.Errorf("files[%d]: from %q must not contain \"..\"", i, fo.From))
+		}
+		if isAbsPath(fo.To) {
+			errs = append(errs, fmt.Errorf("files[%d]: to %q must be relative to the installation root", i, fo.To))
+		} else if hasParentRef(fo.To) {
+			errs = append(errs, fmt.Errorf("files[%d]: to %q must not contain \"..\"", i, fo.To))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// isPublicKey checks if the key is a base64 encoded ed25519 public key with
+// the "ed25519:" prefix.
+func isPublicKey(key string) bool {
+	if !strings.HasPrefix(key, "ed25519:") {
+		return false
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(key, "ed25519:"))
+	return err == nil && len(raw) == ed25519.PublicKeySize
+}
+
+// isAbsPath checks if the slash separated path is absolute on any platform.
+// Windows paths with a drive letter or a leading backslash are detected on
+// all platforms.
+func isAbsPath(p string) bool {
+	if path.IsAbs(p) || filepath.IsAbs(filepath.FromSlash(p)) || filepath.VolumeName(filepath.FromSlash(p)) != "" {
+		return true
+	}
+	if strings.HasPrefix(p, `\`) {
+		return true
+	}
+	return len(p) >= 2 && p[1] == ':' && (p[0] >= 'a' && p[0] <= 'z' || p[0] >= 'A' && p[0] <= 'Z')
+}
+
+// hasParentRef checks if the path has a ".." element.
+func hasParentRef(p string) bool {
+	for _, elem := range strings.Split(strings.Replace(p, "\\", "/", -1), "/") {
+		if elem == ".." {
+			return true
+		}
+	}
+	return false
 }
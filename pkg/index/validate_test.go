@@ -87,7 +87,7 @@ func TestPlugin_Validate(t *testing.T) {
 			fields: fields{
 				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
 				Spec: PluginSpec{
-					Version:          "",
+					Version:          "v1.0.0",
 					ShortDescription: "short",
 					Description:      "",
 					Caveats:          "",
@@ -107,11 +107,152 @@ func TestPlugin_Validate(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "no short description",
+			name: "no version",
 			fields: fields{
 				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
 				Spec: PluginSpec{
 					Version:          "",
+					ShortDescription: "short",
+					Platforms: []Platform{{
+						Head:  "http://example.com",
+						Files: []FileOperation{{"", ""}},
+						Bin:   "foo",
+					}},
+				},
+			},
+			args: args{
+				name: "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "version is not semver",
+			fields: fields{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: PluginSpec{
+					Version:          "1.0",
+					ShortDescription: "short",
+					Platforms: []Platform{{
+						Head:  "http://example.com",
+						Files: []FileOperation{{"", ""}},
+						Bin:   "foo",
+					}},
+				},
+			},
+			args: args{
+				name: "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "deprecated with replacement",
+			fields: fields{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: PluginSpec{
+					Version:            "v1.0.0",
+					ShortDescription:   "short",
+					Deprecated:         true,
+					DeprecationMessage: "foo was renamed",
+					ReplacedBy:         "bar",
+					Platforms: []Platform{{
+						Head:  "http://example.com",
+						Files: []FileOperation{{"", ""}},
+						Bin:   "foo",
+					}},
+				},
+			},
+			args: args{
+				name: "foo",
+			},
+			wantErr: false,
+		},
+		{
+			name: "public key",
+			fields: fields{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: PluginSpec{
+					Version:          "v1.0.0",
+					ShortDescription: "short",
+					PublicKey:        "ed25519:11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=",
+					Platforms: []Platform{{
+						Head:  "http://example.com",
+						Files: []FileOperation{{"", ""}},
+						Bin:   "foo",
+					}},
+				},
+			},
+			args: args{
+				name: "foo",
+			},
+			wantErr: false,
+		},
+		{
+			name: "malformed public key",
+			fields: fields{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: PluginSpec{
+					Version:          "v1.0.0",
+					ShortDescription: "short",
+					PublicKey:        "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=",
+					Platforms: []Platform{{
+						Head:  "http://example.com",
+						Files: []FileOperation{{"", ""}},
+						Bin:   "foo",
+					}},
+				},
+			},
+			args: args{
+				name: "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "replacement without deprecation",
+			fields: fields{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: PluginSpec{
+					Version:          "v1.0.0",
+					ShortDescription: "short",
+					ReplacedBy:       "bar",
+					Platforms: []Platform{{
+						Head:  "http://example.com",
+						Files: []FileOperation{{"", ""}},
+						Bin:   "foo",
+					}},
+				},
+			},
+			args: args{
+				name: "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "replaced by itself",
+			fields: fields{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: PluginSpec{
+					Version:          "v1.0.0",
+					ShortDescription: "short",
+					Deprecated:       true,
+					ReplacedBy:       "other/foo",
+					Platforms: []Platform{{
+						Head:  "http://example.com",
+						Files: []FileOperation{{"", ""}},
+						Bin:   "foo",
+					}},
+				},
+			},
+			args: args{
+				name: "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "no short description",
+			fields: fields{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+				Spec: PluginSpec{
+					Version:          "v1.0.0",
 					ShortDescription: "",
 					Description:      "",
 					Caveats:          "",
@@ -135,7 +276,7 @@ func TestPlugin_Validate(t *testing.T) {
 			fields: fields{
 				ObjectMeta: metav1.ObjectMeta{Name: "foo"},
 				Spec: PluginSpec{
-					Version:          "",
+					Version:          "v1.0.0",
 					ShortDescription: "short",
 					Description:      "",
 					Caveats:          "",
@@ -159,7 +300,7 @@ func TestPlugin_Validate(t *testing.T) {
 			fields: fields{
 				ObjectMeta: metav1.ObjectMeta{Name: "wrong-name"},
 				Spec: PluginSpec{
-					Version:          "",
+					Version:          "v1.0.0",
 					ShortDescription: "short",
 					Description:      "",
 					Caveats:          "",
@@ -183,7 +324,7 @@ func TestPlugin_Validate(t *testing.T) {
 			fields: fields{
 				ObjectMeta: metav1.ObjectMeta{Name: "../foo"},
 				Spec: PluginSpec{
-					Version:          "",
+					Version:          "v1.0.0",
 					ShortDescription: "short",
 					Description:      "",
 					Caveats:          "",
@@ -222,9 +363,12 @@ func TestPlatform_Validate(t *testing.T) {
 		Head     string
 		URI      string
 		Sha256   string
+		Sha512   string
+		Mirrors  []string
 		Selector *metav1.LabelSelector
 		Files    []FileOperation
 		Bin      string
+		Binaries []Binary
 	}
 	tests := []struct {
 		name    string
@@ -303,6 +447,201 @@ func TestPlatform_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid sha256",
+			fields: fields{
+				URI:    "http://example.com",
+				Sha256: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+				Files:  []FileOperation{{"", ""}},
+				Bin:    "foo",
+			},
+			wantErr: false,
+		},
+		{
+			name: "malformed sha256",
+			fields: fields{
+				URI:    "http://example.com",
+				Sha256: "deadbeef",
+				Files:  []FileOperation{{"", ""}},
+				Bin:    "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "sha512 without sha256",
+			fields: fields{
+				URI:    "http://example.com",
+				Sha512: "309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f",
+				Files:  []FileOperation{{"", ""}},
+				Bin:    "foo",
+			},
+			wantErr: false,
+		},
+		{
+			name: "malformed sha512",
+			fields: fields{
+				URI:    "http://example.com",
+				Sha256: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+				Sha512: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+				Files:  []FileOperation{{"", ""}},
+				Bin:    "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "sha512 without uri",
+			fields: fields{
+				Head:   "http://example.com",
+				Sha512: "309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f",
+				Files:  []FileOperation{{"", ""}},
+				Bin:    "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "mirrors",
+			fields: fields{
+				URI:     "http://example.com/a.zip",
+				Sha256:  "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+				Mirrors: []string{"http://mirror.example.com/a.zip"},
+				Files:   []FileOperation{{"", ""}},
+				Bin:     "foo",
+			},
+			wantErr: false,
+		},
+		{
+			name: "mirrors without uri",
+			fields: fields{
+				Head:    "http://example.com",
+				Mirrors: []string{"http://mirror.example.com/a.zip"},
+				Files:   []FileOperation{{"", ""}},
+				Bin:     "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "mirror is not a URI",
+			fields: fields{
+				URI:     "http://example.com/a.zip",
+				Sha256:  "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+				Mirrors: []string{"mirror.example.com/a.zip"},
+				Files:   []FileOperation{{"", ""}},
+				Bin:     "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid selector",
+			fields: fields{
+				Head: "http://example.com",
+				Selector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "os", Operator: "Like"}},
+				},
+				Files: []FileOperation{{"", ""}},
+				Bin:   "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "file operation leaves archive",
+			fields: fields{
+				Head:  "http://example.com",
+				Files: []FileOperation{{"../*", ""}},
+				Bin:   "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "file operation leaves install dir",
+			fields: fields{
+				Head:  "http://example.com",
+				Files: []FileOperation{{"*", "foo/../.."}},
+				Bin:   "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "absolute file operation source",
+			fields: fields{
+				Head:  "http://example.com",
+				Files: []FileOperation{{"/unix/*", "."}},
+				Bin:   "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "absolute file operation target",
+			fields: fields{
+				Head:  "http://example.com",
+				Files: []FileOperation{{"*", `C:\bin`}},
+				Bin:   "foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "bin outside install dir",
+			fields: fields{
+				Head:  "http://example.com",
+				Files: []FileOperation{{"*", "."}},
+				Bin:   "../foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "absolute bin",
+			fields: fields{
+				Head:  "http://example.com",
+				Files: []FileOperation{{"*", "."}},
+				Bin:   "/usr/bin/foo",
+			},
+			wantErr: true,
+		},
+		{
+			name: "binaries",
+			fields: fields{
+				Head:     "http://example.com",
+				Files:    []FileOperation{{"*", "."}},
+				Binaries: []Binary{{Bin: "bin/foo", Name: "foo"}, {Bin: "bin/foo-bar", Name: "foo-bar"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "bin and binaries",
+			fields: fields{
+				Head:     "http://example.com",
+				Files:    []FileOperation{{"*", "."}},
+				Bin:      "foo",
+				Binaries: []Binary{{Bin: "bin/foo", Name: "foo"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate binary names",
+			fields: fields{
+				Head:     "http://example.com",
+				Files:    []FileOperation{{"*", "."}},
+				Binaries: []Binary{{Bin: "a", Name: "foo"}, {Bin: "b", Name: "foo"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsafe binary name",
+			fields: fields{
+				Head:     "http://example.com",
+				Files:    []FileOperation{{"*", "."}},
+				Binaries: []Binary{{Bin: "a", Name: "../foo"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "binary outside install dir",
+			fields: fields{
+				Head:     "http://example.com",
+				Files:    []FileOperation{{"*", "."}},
+				Binaries: []Binary{{Bin: "../a", Name: "foo"}},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -310,9 +649,12 @@ func TestPlatform_Validate(t *testing.T) {
 				Head:     tt.fields.Head,
 				URI:      tt.fields.URI,
 				Sha256:   tt.fields.Sha256,
+				Sha512:   tt.fields.Sha512,
+				Mirrors:  tt.fields.Mirrors,
 				Selector: tt.fields.Selector,
 				Files:    tt.fields.Files,
 				Bin:      tt.fields.Bin,
+				Binaries: tt.fields.Binaries,
 			}
 			if err := p.Validate(); (err != nil) != tt.wantErr {
 				t.Errorf("Platform.Validate() error = %v, wantErr %v", err, tt.wantErr)
@@ -320,3 +662,86 @@ func TestPlatform_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestDependency_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		dep     Dependency
+		wantErr bool
+	}{
+		{name: "plain name", dep: Dependency{Name: "tree"}, wantErr: false},
+		{name: "with index", dep: Dependency{Name: "company/tree"}, wantErr: false},
+		{name: "with constraint", dep: Dependency{Name: "tree", Version: ">=v0.4.0, <v1.0.0"}, wantErr: false},
+		{name: "empty name", dep: Dependency{Name: ""}, wantErr: true},
+		{name: "unsafe name", dep: Dependency{Name: "../tree"}, wantErr: true},
+		{name: "unsafe index", dep: Dependency{Name: "../index/tree"}, wantErr: true},
+		{name: "bad constraint", dep: Dependency{Name: "tree", Version: ">=1.0"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dep.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Dependency.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPluginSpec_validateMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     PluginSpec
+		wantErrs int
+	}{
+		{name: "empty", spec: PluginSpec{}, wantErrs: 0},
+		{
+			name: "all valid",
+			spec: PluginSpec{
+				Homepage:    "https://github.com/example/foo",
+				License:     "Apache-2.0",
+				Maintainers: []Maintainer{{Name: "Platform Team", Email: "platform@example.com"}, {Name: "Jo"}},
+				Tags:        []string{"security", "rbac"},
+				Categories:  []string{"cluster-management"},
+			},
+			wantErrs: 0,
+		},
+		{name: "homepage not a url", spec: PluginSpec{Homepage: "github.com/example/foo"}, wantErrs: 1},
+		{name: "homepage bad scheme", spec: PluginSpec{Homepage: "ftp://example.com"}, wantErrs: 1},
+		{name: "bad license", spec: PluginSpec{License: "Apache 2"}, wantErrs: 1},
+		{name: "maintainer without name", spec: PluginSpec{Maintainers: []Maintainer{{Email: "a@example.com"}}}, wantErrs: 1},
+		{name: "bad email", spec: PluginSpec{Maintainers: []Maintainer{{Name: "a", Email: "Jo <a@example.com>"}}}, wantErrs: 1},
+		{name: "bad tags and categories", spec: PluginSpec{Tags: []string{"Security", "ok"}, Categories: []string{"two words"}}, wantErrs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.spec.validateMetadata(); len(errs) != tt.wantErrs {
+				t.Errorf("validateMetadata() = %v, want %d errors", errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func Test_isSPDXExpression(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"MIT", true},
+		{"GPL-2.0+", true},
+		{"LicenseRef-Proprietary", true},
+		{"MIT OR Apache-2.0", true},
+		{"(MIT OR Apache-2.0) AND BSD-3-Clause", true},
+		{"GPL-2.0-only WITH Classpath-exception-2.0", true},
+		{"", false},
+		{"MIT OR", false},
+		{"MIT Apache-2.0", false},
+		{"(MIT", false},
+		{"MIT)", false},
+		{"Apache License", false},
+		{"MIT/X11", false},
+	}
+	for _, tt := range tests {
+		if got := isSPDXExpression(tt.expr); got != tt.want {
+			t.Errorf("isSPDXExpression(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validation checks all manifests of a plugin index. It reports more
+// than the checks krew runs when loading a plugin, and is meant for index
+// maintainers.
+package validation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/krew/pkg/index"
+	"github.com/GoogleContainerTools/krew/pkg/index/indexscanner"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// commonPlatforms are checked for platforms with overlapping selectors.
+var commonPlatforms = []labels.Set{
+	{"os": "darwin", "arch": "amd64"},
+	{"os": "linux", "arch": "amd64"},
+	{"os": "linux", "arch": "386"},
+	{"os": "linux", "arch": "arm"},
+	{"os": "linux", "arch": "arm64"},
+	{"os": "windows", "arch": "amd64"},
+	{"os": "windows", "arch": "386"},
+}
+
+// Report is the result of validating an index.
+type Report struct {
+	// Checked is the number of files that were checked.
+	Checked int `json:"checked"`
+	// Files lists the files that have problems.
+	Files []FileReport `json:"files"`
+}
+
+// FileReport lists the problems of one file in the index.
+type FileReport struct {
+	// File is the path of the file relative to the index directory.
+	File     string   `json:"file"`
+	Problems []string `json:"problems"`
+}
+
+// ProblemCount returns the number of problems in all files.
+func (r Report) ProblemCount() int {
+	var n int
+	for _, f := range r.Files {
+		n += len(f.Problems)
+	}
+	return n
+}
+
+// ValidateIndex checks every file in the plugins directory of the index.
+func ValidateIndex(indexDir string) (Report, error) {
+	var report Report
+	files, err := ioutil.ReadDir(filepath.Join(indexDir, "plugins"))
+	if err != nil {
+		return report, fmt.Errorf("failed to read the plugins directory, err: %v", err)
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		report.Checked++
+		file := path.Join("plugins", f.Name())
+		var problems []string
+		if filepath.Ext(f.Name()) != ".yaml" {
+			problems = []string{"only .yaml plugin manifests are allowed in the plugins directory"}
+		} else {
+			problems = validateManifest(indexDir, filepath.Join(indexDir, filepath.FromSlash(file)), strings.TrimSuffix(f.Name(), ".yaml"))
+		}
+		if len(problems) > 0 {
+			report.Files = append(report.Files, FileReport{File: file, Problems: problems})
+		}
+	}
+	return report, nil
+}
+
+// validateManifest returns the problems of the manifest for the named plugin.
+func validateManifest(indexDir, file, name string) []string {
+	plugin, err := indexscanner.ReadPluginFile(file)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var problems []string
+	if err := plugin.Validate(name); err != nil {
+		agg, ok := err.(utilerrors.Aggregate)
+		if !ok {
+			agg = utilerrors.NewAggregate([]error{err})
+		}
+		for _, e := range utilerrors.Flatten(agg).Errors() {
+			problems = append(problems, e.Error())
+		}
+	}
+	problems = append(problems, findOverlappingPlatforms(plugin)...)
+	return append(problems, findMissingDependencies(indexDir, plugin)...)
+}
+
+// findMissingDependencies reports dependencies on plugins from the same index
+// that have no manifest in the index. Dependencies on other indexes can't be
+// checked.
+func findMissingDependencies(indexDir string, plugin index.Plugin) []string {
+	var missing []string
+	for i, dep := range plugin.Spec.Dependencies {
+		if dep.IndexName() != "" || !index.IsSafePluginName(dep.PluginName()) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(indexDir, "plugins", dep.PluginName()+".yaml")); os.IsNotExist(err) {
+			missing = append(missing, fmt.Sprintf("dependencies[%d]: plugin %q is not in the index", i, dep.Name))
+		}
+	}
+	return missing
+}
+
+// findOverlappingPlatforms reports common platforms that are matched by more
+// than one platform selector. Krew picks the first match, which hides the
+// other platforms.
+func findOverlappingPlatforms(plugin index.Plugin) []string {
+	var overlaps []string
+	envsByOverlap := make(map[string][]string)
+	for _, env := range commonPlatforms {
+		var matches []string
+		for i, p := range plugin.Spec.Platforms {
+			sel, err := metav1.LabelSelectorAsSelector(p.Selector)
+			if err != nil {
+				// Reported by Platform.Validate.
+				continue
+			}
+			if sel.Matches(env) {
+				matches = append(matches, fmt.Sprintf("platforms[%d]", i))
+			}
+		}
+		if len(matches) < 2 {
+			continue
+		}
+		overlap := strings.Join(matches, ", ")
+		if _, ok := envsByOverlap[overlap]; !ok {
+			overlaps = append(overlaps, overlap)
+		}
+		envsByOverlap[overlap] = append(envsByOverlap[overlap], "{"+env.String()+"}")
+	}
+
+	var problems []string
+	for _, overlap := range overlaps {
+		problems = append(problems, fmt.Sprintf("%s overlap, they all match %s", overlap, strings.Join(envsByOverlap[overlap], " ")))
+	}
+	return problems
+}
@@ -0,0 +1,66 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateIndex(t *testing.T) {
+	report, err := ValidateIndex(filepath.Join("testdata", "index"))
+	if err != nil {
+		t.Fatalf("ValidateIndex() error = %v", err)
+	}
+	if report.Checked != 4 {
+		t.Errorf("ValidateIndex() checked %d files, want 4", report.Checked)
+	}
+
+	problems := make(map[string][]string)
+	for _, f := range report.Files {
+		problems[f.File] = f.Problems
+	}
+	if p, ok := problems["plugins/good.yaml"]; ok {
+		t.Errorf("ValidateIndex() reported problems for a valid manifest: %v", p)
+	}
+	if p := problems["plugins/README.md"]; len(p) != 1 {
+		t.Errorf("ValidateIndex() problems for a non-manifest file = %v, want 1", p)
+	}
+	if p := problems["plugins/typo.yaml"]; len(p) != 1 || !strings.Contains(p[0], `unknown field "sha265"`) {
+		t.Errorf("ValidateIndex() problems for a manifest with a typo = %v", p)
+	}
+
+	wantBad := []string{
+		`should be named "bad"`,
+		`sha256 "nothex"`,
+		`bin "../kubectl-bad"`,
+		`files[0]: from "/unix/*" must be relative`,
+		"platforms[0], platforms[1] overlap",
+		`dependencies[0]: plugin "missing" is not in the index`,
+	}
+	gotBad := problems["plugins/bad.yaml"]
+	if len(gotBad) != len(wantBad) {
+		t.Fatalf("ValidateIndex() problems for bad.yaml = %q, want %d problems", gotBad, len(wantBad))
+	}
+	for i, want := range wantBad {
+		if !strings.Contains(gotBad[i], want) {
+			t.Errorf("ValidateIndex() problem %d for bad.yaml = %q, want it to contain %q", i, gotBad[i], want)
+		}
+	}
+	if got, want := report.ProblemCount(), 2+len(wantBad); got != want {
+		t.Errorf("ProblemCount() = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/krew/pkg/environment"
+	"github.com/GoogleContainerTools/krew/pkg/index"
+	"github.com/GoogleContainerTools/krew/pkg/index/indexoperations"
+	"github.com/GoogleContainerTools/krew/pkg/receipt"
+	"github.com/GoogleContainerTools/krew/pkg/semver"
+	"github.com/golang/glog"
+)
+
+// PluginLoader loads the manifest of a plugin from the named index.
+type PluginLoader func(indexName, pluginName string) (index.Plugin, error)
+
+// pendingInstall is a plugin that is going to be installed.
+type pendingInstall struct {
+	plugin    index.Plugin
+	indexName string
+}
+
+// resolver walks the dependency graph of a plugin.
+type resolver struct {
+	load PluginLoader
+	// installedVersion returns the installed version of a plugin.
+	installedVersion func(name string) (string, bool, error)
+
+	// resolved holds the manifests of all visited plugins by name.
+	resolved map[string]index.Plugin
+	// visiting is the path from the root to the current plugin, it is used
+	// to detect cycles.
+	visiting []string
+	order    []pendingInstall
+}
+
+// resolveDependencies returns the plugins that need to be installed for the
+// plugin in installation order. Dependencies come before the plugins that
+// depend on them and the plugin itself is last. Dependencies that are already
+// installed are not returned.
+func resolveDependencies(p environment.Paths, plugin index.Plugin, indexName string, load PluginLoader) ([]pendingInstall, error) {
+	r := &resolver{
+		load: load,
+		installedVersion: func(name string) (string, bool, error) {
+			return findInstalledPluginVersion(p.InstallPath(), p.BinPath(), name)
+		},
+		resolved: make(map[string]index.Plugin),
+	}
+	if err := r.visit(plugin, indexName); err != nil {
+		return nil, err
+	}
+	return r.order, nil
+}
+
+func (r *resolver) visit(plugin index.Plugin, indexName string) error {
+	r.visiting = append(r.visiting, plugin.Name)
+	defer func() { r.visiting = r.visiting[:len(r.visiting)-1] }()
+	r.resolved[plugin.Name] = plugin
+
+	for _, dep := range plugin.Spec.Dependencies {
+		if err := r.visitDependency(plugin.Name, indexName, dep); err != nil {
+			return err
+		}
+	}
+	r.order = append(r.order, pendingInstall{plugin: plugin, indexName: indexName})
+	return nil
+}
+
+func (r *resolver) visitDependency(parent, parentIndex string, dep index.Dependency) error {
+	name := dep.PluginName()
+	for i, n := range r.visiting {
+		if n == name {
+			cycle := append(append([]string{}, r.visiting[i:]...), name)
+			return fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	constraint, err := semver.ParseConstraint(dep.Version)
+	if err != nil {
+		return fmt.Errorf("invalid dependency %q of plugin %q, err: %v", dep.Name, parent, err)
+	}
+
+	if plugin, ok := r.resolved[name]; ok {
+		// Already going to be installed for another plugin.
+		return checkConstraint(parent, name, plugin.Spec.Version, constraint)
+	}
+
+	version, installed, err := r.installedVersion(name)
+	if err != nil {
+		return fmt.Errorf("failed to find the installed version of dependency %q, err: %v", name, err)
+	}
+	if installed {
+		glog.V(2).Infof("Dependency %s of %s is installed with version %s", name, parent, version)
+		if version == headVersion {
+			return nil
+		}
+		if err := checkConstraint(parent, name, version, constraint); err != nil {
+			return fmt.Errorf("%v, upgrade it first", err)
+		}
+		return nil
+	}
+
+	indexName := dep.IndexName()
+	if indexName == "" {
+		indexName = parentIndex
+	}
+	if r.load == nil {
+		return fmt.Errorf("can't load dependency %q of plugin %q, no plugin loader given", dep.Name, parent)
+	}
+	plugin, err := r.load(indexName, name)
+	if err != nil {
+		return fmt.Errorf("failed to load dependency %q of plugin %q, err: %v", dep.Name, parent, err)
+	}
+	if err := checkConstraint(parent, name, plugin.Spec.Version, constraint); err != nil {
+		return err
+	}
+	return r.visit(plugin, indexName)
+}
+
+// checkConstraint checks that the version of the dependency satisfies the
+// constraint. Versions that can't be parsed, like sha256 named installations,
+// only satisfy empty constraints.
+func checkConstraint(parent, name, version string, constraint semver.Constraint) error {
+	if constraint.String() == "" {
+		return nil
+	}
+	v, err := semver.Parse(version)
+	if err != nil || !constraint.Check(v) {
+		return fmt.Errorf("plugin %q requires %s %s, but found version %q", parent, name, constraint, version)
+	}
+	return nil
+}
+
+// Dependents returns the sorted names of installed plugins that depend on the
+// named plugin, according to their install receipts. Plugins that depend on a
+// plugin with the same name from another index are not returned.
+func Dependents(p environment.Paths, name string) ([]string, error) {
+	receipts, err := receipt.LoadAll(p.InstallReceiptsPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read install receipts, err: %v", err)
+	}
+	indexName := indexoperations.DefaultIndexName
+	for _, r := range receipts {
+		if r.Name == name {
+			indexName = receiptIndex(r)
+		}
+	}
+	var dependents []string
+	for _, r := range receipts {
+		for _, dep := range r.Spec.Dependencies {
+			depIndex := dep.IndexName()
+			if depIndex == "" {
+				depIndex = receiptIndex(r)
+			}
+			if depIndex == indexName && dep.PluginName() == name {
+				dependents = append(dependents, r.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents, nil
+}
+
+// receiptIndex returns the index a plugin was installed from. Plugins
+// installed before receipts recorded the index are from the default index.
+func receiptIndex(r index.Receipt) string {
+	if r.Status.Source.Name == "" {
+		return indexoperations.DefaultIndexName
+	}
+	return r.Status.Source.Name
+}
@@ -0,0 +1,144 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/krew/pkg/environment"
+	"github.com/GoogleContainerTools/krew/pkg/index"
+	"github.com/GoogleContainerTools/krew/pkg/receipt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPlugin(name, version string, deps ...index.Dependency) index.Plugin {
+	return index.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       index.PluginSpec{Version: version, Dependencies: deps},
+	}
+}
+
+func Test_resolver(t *testing.T) {
+	plugins := map[string]index.Plugin{
+		"default/a":  testPlugin("a", "v1.0.0", index.Dependency{Name: "b"}, index.Dependency{Name: "c", Version: ">=v1.0.0"}),
+		"default/b":  testPlugin("b", "v1.0.0", index.Dependency{Name: "c", Version: "^v1.2.0"}),
+		"default/c":  testPlugin("c", "v1.2.3"),
+		"default/d":  testPlugin("d", "v1.0.0", index.Dependency{Name: "c", Version: "<v1.0.0"}),
+		"default/e":  testPlugin("e", "v1.0.0", index.Dependency{Name: "f"}),
+		"default/f":  testPlugin("f", "v1.0.0", index.Dependency{Name: "e"}),
+		"default/g":  testPlugin("g", "v1.0.0", index.Dependency{Name: "other/h"}),
+		"other/h":    testPlugin("h", "v2.0.0", index.Dependency{Name: "i"}),
+		"other/i":    testPlugin("i", "v2.0.0"),
+		"default/j":  testPlugin("j", "v1.0.0", index.Dependency{Name: "installed", Version: ">=v2.0.0"}),
+		"default/k":  testPlugin("k", "v1.0.0", index.Dependency{Name: "installed", Version: "^v1.0.0"}),
+		"default/l":  testPlugin("l", "v1.0.0", index.Dependency{Name: "missing"}),
+		"default/mm": testPlugin("mm", "v1.0.0", index.Dependency{Name: "mm"}),
+	}
+	load := func(indexName, name string) (index.Plugin, error) {
+		p, ok := plugins[indexName+"/"+name]
+		if !ok {
+			return index.Plugin{}, fmt.Errorf("plugin %s/%s not found", indexName, name)
+		}
+		return p, nil
+	}
+	installedVersion := func(name string) (string, bool, error) {
+		if name == "installed" {
+			return "v1.5.0", true, nil
+		}
+		return "", false, nil
+	}
+
+	tests := []struct {
+		name      string
+		plugin    string
+		want      []string
+		wantError string
+	}{
+		{name: "no dependencies", plugin: "c", want: []string{"default/c"}},
+		{name: "dependencies first", plugin: "a", want: []string{"default/c", "default/b", "default/a"}},
+		{name: "unsatisfied constraint", plugin: "d", wantError: "requires c <v1.0.0"},
+		{name: "cycle", plugin: "e", wantError: "e -> f -> e"},
+		{name: "self cycle", plugin: "mm", wantError: "mm -> mm"},
+		{name: "other index", plugin: "g", want: []string{"other/i", "other/h", "default/g"}},
+		{name: "installed too old", plugin: "j", wantError: "upgrade it first"},
+		{name: "installed", plugin: "k", want: []string{"default/k"}},
+		{name: "missing", plugin: "l", wantError: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &resolver{
+				load:             load,
+				installedVersion: installedVersion,
+				resolved:         make(map[string]index.Plugin),
+			}
+			err := r.visit(plugins["default/"+tt.plugin], "default")
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("visit() error = %v, want error containing %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("visit() error = %v", err)
+			}
+			var got []string
+			for _, pi := range r.order {
+				got = append(got, pi.indexName+"/"+pi.plugin.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("visit() order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependents(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "krew-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	os.Setenv("KREW_ROOT", tmpDir)
+	defer os.Unsetenv("KREW_ROOT")
+	p := environment.MustGetKrewPaths()
+
+	for _, plugin := range []index.Plugin{
+		testPlugin("tree", "v1.0.0"),
+		testPlugin("wrapper", "v1.0.0", index.Dependency{Name: "tree"}),
+		testPlugin("another", "v1.0.0", index.Dependency{Name: "default/tree"}),
+		testPlugin("unrelated", "v1.0.0", index.Dependency{Name: "other/tree"}),
+	} {
+		if err := receipt.Store(receipt.New(plugin, "default"), p.PluginInstallReceiptPath(plugin.Name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Dependents(p, "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"another", "wrapper"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dependents() = %v, want %v", got, want)
+	}
+	if got, _ := Dependents(p, "wrapper"); len(got) != 0 {
+		t.Errorf("Dependents() = %v, want none", got)
+	}
+}
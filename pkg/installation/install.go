@@ -15,6 +15,7 @@
 package installation
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -25,6 +26,7 @@ import (
 	"github.com/GoogleContainerTools/krew/pkg/environment"
 	"github.com/GoogleContainerTools/krew/pkg/index"
 	"github.com/GoogleContainerTools/krew/pkg/pathutil"
+	"github.com/GoogleContainerTools/krew/pkg/receipt"
 
 	"github.com/golang/glog"
 )
@@ -34,6 +36,7 @@ var (
 	ErrIsAlreadyInstalled = fmt.Errorf("can't install, the newest version is already installed")
 	ErrIsNotInstalled     = fmt.Errorf("plugin is not installed")
 	ErrIsAlreadyUpgraded  = fmt.Errorf("can't upgrade, the newest version is already installed")
+	ErrIsDowngrade        = fmt.Errorf("can't upgrade, the installed version is newer than the version in the index")
 )
 
 const (
@@ -42,30 +45,84 @@ const (
 	krewPluginName = "krew"
 )
 
-func downloadAndMove(version, uri string, fos []index.FileOperation, downloadPath, installPath string) (dst string, err error) {
+// DefaultDownloadCacheSize is the size limit of the download cache in bytes
+// if none is configured.
+const DefaultDownloadCacheSize = 1 << 30
+
+// DownloadCache returns the cache of verified plugin archives with the size
+// limit in bytes. Zero disables the limit.
+func DownloadCache(p environment.Paths, maxSize int64) *download.Cache {
+	return &download.Cache{Dir: p.DownloadCachePath(), PartialDir: p.DownloadPartialPath(), MaxSize: maxSize}
+}
+
+// pluginDownloader returns the downloader for the archive of the plugin.
+// Without a fetcher, archives are fetched from HTTP and file URIs.
+func pluginDownloader(d download.Downloader, plugin string) download.Downloader {
+	if d.Fetcher == nil {
+		d.Fetcher = download.NewSchemes(download.NewHTTPFetcher())
+	}
+	d.Label = plugin
+	return d
+}
+
+// downloadArchive downloads and extracts the plugin archive to the download
+// path. It does not change the plugin store, so it can run concurrently with
+// other installations. The caller has to remove the download path.
+func downloadArchive(ctx context.Context, d download.Downloader, version string, uris []string, verification download.Verification, downloadPath string) error {
 	glog.V(3).Infof("Creating download dir %q", downloadPath)
-	if err = os.MkdirAll(downloadPath, 0755); err != nil {
-		return "", fmt.Errorf("could not create download path %q, err: %v", downloadPath, err)
+	if err := os.MkdirAll(downloadPath, 0755); err != nil {
+		return fmt.Errorf("could not create download path %q, err: %v", downloadPath, err)
 	}
-	defer os.RemoveAll(downloadPath)
 
 	if version == headVersion {
 		glog.V(1).Infof("Getting latest version from HEAD")
-		err = download.GetInsecure(uri, downloadPath, download.HTTPFetcher{})
 	} else {
-		glog.V(1).Infof("Getting sha256 (%s) signed version", version)
-		err = download.GetWithSha256(uri, downloadPath, version, download.HTTPFetcher{})
+		glog.V(1).Infof("Getting version %s with sha256 (%s)", version, verification.Sha256)
 	}
+	return d.Get(ctx, uris, downloadPath, verification)
+}
+
+// moveArchive moves the extracted archive to the install path. The caller has
+// to hold the store lock.
+func moveArchive(d download.Downloader, version string, fos []index.FileOperation, downloadPath, installPath string) (string, error) {
+	if d.Progress != nil {
+		d.Progress.Report(download.Progress{Label: d.Label, Phase: download.PhaseMove, Total: -1})
+	}
+	dst, err := moveToInstallDir(downloadPath, installPath, version, fos)
 	if err != nil {
 		return "", err
 	}
+	if d.Progress != nil {
+		d.Progress.Report(download.Progress{Label: d.Label, Phase: download.PhaseMove, Finished: true})
+	}
+	return dst, nil
+}
 
-	return moveToInstallDir(downloadPath, installPath, version, fos)
+// InstallOpts changes how a plugin is installed.
+type InstallOpts struct {
+	// ForceHEAD installs the HEAD version of the plugin. Dependencies are
+	// always installed from their versioned URI if they have one.
+	ForceHEAD bool
+	// Load loads the manifests of dependencies. Plugins with dependencies that
+	// are not installed yet can't be installed without it.
+	Load PluginLoader
+	// InstalledDependency is called with the name of each dependency that
+	// was installed for the plugin.
+	InstalledDependency func(name string)
+	// Downloader downloads the archives of the plugin and its dependencies.
+	// Its label is set to the name of each plugin.
+	Downloader download.Downloader
+	// PluginFetcher fetches the archive of the plugin instead of the
+	// fetcher of Downloader, but not the archives of its dependencies. A
+	// manifest of the user can use URI schemes that an index can't.
+	PluginFetcher download.Fetcher
 }
 
-// Install will download and install a plugin. The operation tries
-// to not get the plugin dir in a bad state if it fails during the process.
-func Install(p environment.Paths, plugin index.Plugin, forceHEAD bool) error {
+// Install will download and install a plugin from the named index, after
+// installing the dependencies that are missing. The operation tries to not
+// get the plugin dir in a bad state if it fails during the process. Plugins
+// can be installed concurrently.
+func Install(ctx context.Context, p environment.Paths, plugin index.Plugin, indexName string, opts InstallOpts) error {
 	glog.V(2).Infof("Looking for installed versions")
 	_, ok, err := findInstalledPluginVersion(p.InstallPath(), p.BinPath(), plugin.Name)
 	if err != nil {
@@ -75,16 +132,90 @@ func Install(p environment.Paths, plugin index.Plugin, forceHEAD bool) error {
 		return ErrIsAlreadyInstalled
 	}
 
+	glog.V(2).Infof("Resolving dependencies of plugin %s", plugin.Name)
+	pending, err := resolveDependencies(p, plugin, indexName, opts.Load)
+	if err != nil {
+		return fmt.Errorf("failed to resolve dependencies, err: %v", err)
+	}
+	for _, pi := range pending {
+		if err := index.CheckKrewVersion(pi.plugin); err != nil {
+			return err
+		}
+	}
+	for _, pi := range pending {
+		isDependency := pi.plugin.Name != plugin.Name
+		forceHEAD := opts.ForceHEAD && !isDependency
+		d := opts.Downloader
+		if !isDependency && opts.PluginFetcher != nil {
+			d.Fetcher = opts.PluginFetcher
+		}
+		err := installOne(ctx, d, p, pi.plugin, pi.indexName, forceHEAD)
+		if isDependency && err == ErrIsAlreadyInstalled {
+			// Another installation that is running at the same time
+			// installed the dependency.
+			continue
+		}
+		if err != nil {
+			if isDependency {
+				return fmt.Errorf("failed to install dependency %q, err: %v", pi.plugin.Name, err)
+			}
+			return err
+		}
+		if isDependency && opts.InstalledDependency != nil {
+			opts.InstalledDependency(pi.plugin.Name)
+		}
+	}
+	return nil
+}
+
+func installOne(ctx context.Context, d download.Downloader, p environment.Paths, plugin index.Plugin, indexName string, forceHEAD bool) error {
+	defer lockPlugin(plugin.Name)()
+	_, ok, err := findInstalledPluginVersion(p.InstallPath(), p.BinPath(), plugin.Name)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return ErrIsAlreadyInstalled
+	}
+
 	glog.V(1).Infof("Finding download target for plugin %s", plugin.Name)
-	version, uri, fos, bin, err := getDownloadTarget(plugin, forceHEAD)
+	version, verification, uris, fos, bins, bare, err := getDownloadTarget(plugin, forceHEAD)
 	if err != nil {
 		return err
 	}
-	return install(plugin.Name, version, uri, bin, p, fos)
+	d.BareBinary = bare
+	if err := install(ctx, pluginDownloader(d, plugin.Name), version, uris, verification, bins, p, fos); err != nil {
+		return err
+	}
+	glog.V(3).Infof("Storing install receipt for plugin %s", plugin.Name)
+	return withStoreLock(func() error {
+		return receipt.Store(receipt.New(plugin, indexName), p.PluginInstallReceiptPath(plugin.Name))
+	})
 }
 
-func install(plugin, version, uri, bin string, p environment.Paths, fos []index.FileOperation) error {
-	dst, err := downloadAndMove(version, uri, fos, filepath.Join(p.DownloadPath(), plugin), p.PluginInstallPath(plugin))
+// install downloads the plugin and links its commands. The download runs
+// without the store lock, the plugin is moved to the store and linked under
+// it. The label of the downloader is the plugin name.
+func install(ctx context.Context, d download.Downloader, version string, uris []string, verification download.Verification, bins []index.Binary, p environment.Paths, fos []index.FileOperation) error {
+	plugin := d.Label
+	// Check for commands of other plugins before downloading anything.
+	if err := withStoreLock(func() error { return checkLinkConflicts(p, plugin, bins) }); err != nil {
+		return err
+	}
+
+	downloadPath := filepath.Join(p.DownloadPath(), plugin)
+	defer os.RemoveAll(downloadPath)
+	if err := downloadArchive(ctx, d, version, uris, verification, downloadPath); err != nil {
+		return fmt.Errorf("failed to dowload and move during installation, err: %v", err)
+	}
+
+	storeLock.Lock()
+	defer storeLock.Unlock()
+	// Another plugin could have linked the commands during the download.
+	if err := checkLinkConflicts(p, plugin, bins); err != nil {
+		return err
+	}
+	dst, err := moveArchive(d, version, fos, downloadPath, p.PluginInstallPath(plugin))
 	if err != nil {
 		return fmt.Errorf("failed to dowload and move during installation, err: %v", err)
 	}
@@ -93,15 +224,37 @@ func install(plugin, version, uri, bin string, p environment.Paths, fos []index.
 	if err != nil {
 		return fmt.Errorf("failed to get the absolute fullPath of %q, err: %v", dst, err)
 	}
-	fullPath := filepath.Join(dst, filepath.FromSlash(bin))
-	pathAbs, err := filepath.Abs(fullPath)
-	if err != nil {
-		return fmt.Errorf("failed to get the absolute fullPath of %q, err: %v", fullPath, err)
+	for _, b := range bins {
+		fullPath := filepath.Join(dst, filepath.FromSlash(b.Bin))
+		pathAbs, err := filepath.Abs(fullPath)
+		if err != nil {
+			return fmt.Errorf("failed to get the absolute fullPath of %q, err: %v", fullPath, err)
+		}
+		if _, ok := pathutil.IsSubPath(subPathAbs, pathAbs); !ok {
+			return fmt.Errorf("the fullPath %q does not extend the sub-fullPath %q", fullPath, dst)
+		}
+	}
+	for _, b := range bins {
+		if err := createOrUpdateLink(p.BinPath(), filepath.Join(dst, filepath.FromSlash(b.Bin)), b.Name); err != nil {
+			return err
+		}
 	}
-	if _, ok := pathutil.IsSubPath(subPathAbs, pathAbs); !ok {
-		return fmt.Errorf("the fullPath %q does not extend the sub-fullPath %q", fullPath, dst)
+	return nil
+}
+
+// checkLinkConflicts checks that no other plugin provides the commands of the
+// plugin.
+func checkLinkConflicts(p environment.Paths, plugin string, bins []index.Binary) error {
+	for _, b := range bins {
+		link, ok, err := readPluginLink(p.InstallPath(), filepath.Join(p.BinPath(), pluginNameToBin(b.Name, isWindows())))
+		if err != nil {
+			return err
+		}
+		if ok && link.plugin != plugin {
+			return fmt.Errorf("can't link command %q, it is provided by plugin %q", b.Name, link.plugin)
+		}
 	}
-	return createOrUpdateLink(p.BinPath(), filepath.Join(dst, filepath.FromSlash(bin)), plugin)
+	return nil
 }
 
 // Remove will remove a plugin.
@@ -109,6 +262,8 @@ func Remove(p environment.Paths, name string) error {
 	if name == krewPluginName {
 		return fmt.Errorf("removing krew is not allowed through krew, see docs for help")
 	}
+	storeLock.Lock()
+	defer storeLock.Unlock()
 	glog.V(3).Infof("Finding installed version to delete")
 	version, installed, err := findInstalledPluginVersion(p.InstallPath(), p.BinPath(), name)
 	if err != nil {
@@ -120,11 +275,22 @@ func Remove(p environment.Paths, name string) error {
 	glog.V(1).Infof("Deleting plugin version %s", version)
 	glog.V(3).Infof("Deleting path %q", p.PluginInstallPath(name))
 
-	symlinkPath := filepath.Join(p.BinPath(), pluginNameToBin(name, isWindows()))
-	if err := removeLink(symlinkPath); err != nil {
-		return fmt.Errorf("could not uninstall symlink of plugin: %+v", err)
+	links, err := findPluginLinks(p.InstallPath(), p.BinPath(), name)
+	if err != nil {
+		return fmt.Errorf("could not find symlinks of plugin, err: %v", err)
+	}
+	for _, l := range links {
+		if err := removeLink(l.path); err != nil {
+			return fmt.Errorf("could not uninstall symlink of plugin: %+v", err)
+		}
 	}
-	return os.RemoveAll(p.PluginInstallPath(name))
+	if err := os.RemoveAll(p.PluginInstallPath(name)); err != nil {
+		return fmt.Errorf("could not remove plugin directory, err: %v", err)
+	}
+	if err := os.Remove(p.PluginInstallReceiptPath(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not remove plugin receipt, err: %v", err)
+	}
+	return nil
 }
 
 func createOrUpdateLink(binDir string, binary string, plugin string) error {
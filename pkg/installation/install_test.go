@@ -15,12 +15,15 @@
 package installation
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sync"
 	"testing"
 
+	"github.com/GoogleContainerTools/krew/pkg/download"
 	"github.com/GoogleContainerTools/krew/pkg/index"
 )
 
@@ -197,3 +200,32 @@ func Test_removeLink_regularFileExists(t *testing.T) {
 		t.Fatalf("removeLink(%s) with regular file was expected to fail; got: err=nil", path)
 	}
 }
+
+func TestInstall_downloader(t *testing.T) {
+	p, withPlatform, cleanup := setupKrewRoot(t)
+	defer cleanup()
+	dep := withPlatform(testPlugin("dep", "v1.0.0"))
+	plugin := withPlatform(testPlugin("a", "v1.0.0", index.Dependency{Name: "dep"}))
+
+	var mu sync.Mutex
+	finished := make(map[string][]download.Phase)
+	opts := InstallOpts{
+		Load: func(_, name string) (index.Plugin, error) { return dep, nil },
+		Downloader: download.Downloader{Progress: download.ProgressFunc(func(e download.Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			if e.Finished {
+				finished[e.Label] = append(finished[e.Label], e.Phase)
+			}
+		})},
+	}
+	if err := Install(context.Background(), p, plugin, "default", opts); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	want := []download.Phase{download.PhaseDownload, download.PhaseVerify, download.PhaseExtract, download.PhaseMove}
+	for _, name := range []string{"a", "dep"} {
+		if !reflect.DeepEqual(finished[name], want) {
+			t.Errorf("finished phases of %s = %v, want %v", name, finished[name], want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/krew/pkg/pathutil"
+)
+
+// pluginLink is a symlink in the bin directory that points into a plugin
+// installation.
+type pluginLink struct {
+	path    string
+	plugin  string
+	version string
+}
+
+// readPluginLink reads the link at linkPath. It returns false if there is no
+// symlink into the install path.
+func readPluginLink(installPath, linkPath string) (pluginLink, bool, error) {
+	link, err := os.Readlink(linkPath)
+	if os.IsNotExist(err) {
+		return pluginLink{}, false, nil
+	} else if err != nil {
+		if fi, statErr := os.Lstat(linkPath); statErr == nil && fi.Mode()&os.ModeSymlink == 0 {
+			// Not a symlink.
+			return pluginLink{}, false, nil
+		}
+		return pluginLink{}, false, fmt.Errorf("could not read plugin link, err: %v", err)
+	}
+	if !filepath.IsAbs(link) {
+		if link, err = filepath.Abs(filepath.Join(filepath.Dir(linkPath), link)); err != nil {
+			return pluginLink{}, false, fmt.Errorf("failed to get the absolute path for the link of %q, err: %v", linkPath, err)
+		}
+	}
+	// plugin path: {install_path}/{plugin_name}/{version}/...
+	elems, ok := pathutil.IsSubPath(installPath, link)
+	if !ok || len(elems) < 2 {
+		return pluginLink{}, false, nil
+	}
+	return pluginLink{path: linkPath, plugin: elems[0], version: elems[1]}, true, nil
+}
+
+// findPluginLinks returns all links in the bin directory that point into the
+// installation of the plugin.
+func findPluginLinks(installPath, binDir, pluginName string) ([]pluginLink, error) {
+	files, err := ioutil.ReadDir(binDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read bin dir, err: %v", err)
+	}
+	var links []pluginLink
+	for _, f := range files {
+		if f.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		l, ok, err := readPluginLink(installPath, filepath.Join(binDir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if ok && l.plugin == pluginName {
+			links = append(links, l)
+		}
+	}
+	return links, nil
+}
@@ -0,0 +1,122 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// setupLinks creates an install dir with plugin foo, which has the commands
+// foo-a and foo-b, and plugin bar.
+func setupLinks(t *testing.T) (installPath, binDir string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "links-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	installPath = filepath.Join(dir, "store")
+	binDir = filepath.Join(dir, "bin")
+	for _, d := range []string{filepath.Join(installPath, "foo", "v1.0.0"), filepath.Join(installPath, "bar", "v0.1.0"), binDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"kubectl-foo_a": filepath.Join(installPath, "foo", "v1.0.0", "a"),
+		"kubectl-foo_b": filepath.Join(installPath, "foo", "v1.0.0", "b"),
+		"kubectl-bar":   filepath.Join(installPath, "bar", "v0.1.0", "bar"),
+		"kubectl-other": os.TempDir(),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(binDir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(binDir, "kubectl-regular"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return installPath, binDir, func() { os.RemoveAll(dir) }
+}
+
+func Test_findPluginLinks(t *testing.T) {
+	installPath, binDir, cleanup := setupLinks(t)
+	defer cleanup()
+
+	tests := []struct {
+		plugin string
+		want   []string
+	}{
+		{plugin: "foo", want: []string{"kubectl-foo_a", "kubectl-foo_b"}},
+		{plugin: "bar", want: []string{"kubectl-bar"}},
+		{plugin: "baz", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.plugin, func(t *testing.T) {
+			links, err := findPluginLinks(installPath, binDir, tt.plugin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, l := range links {
+				got = append(got, filepath.Base(l.path))
+				if l.plugin != tt.plugin {
+					t.Errorf("link %q belongs to plugin %q, want %q", l.path, l.plugin, tt.plugin)
+				}
+			}
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("findPluginLinks() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("findPluginLinks() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_findInstalledPluginVersion_withoutPluginCommand(t *testing.T) {
+	installPath, binDir, cleanup := setupLinks(t)
+	defer cleanup()
+
+	version, installed, err := findInstalledPluginVersion(installPath, binDir, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !installed || version != "v1.0.0" {
+		t.Errorf("findInstalledPluginVersion() = %q, %v, want %q, true", version, installed, "v1.0.0")
+	}
+}
+
+func Test_findInstalledPluginVersion_commandOfOtherPlugin(t *testing.T) {
+	installPath, binDir, cleanup := setupLinks(t)
+	defer cleanup()
+	// Plugin foo provides a command named baz.
+	if err := os.Symlink(filepath.Join(installPath, "foo", "v1.0.0", "baz"), filepath.Join(binDir, "kubectl-baz")); err != nil {
+		t.Fatal(err)
+	}
+
+	version, installed, err := findInstalledPluginVersion(installPath, binDir, "baz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if installed {
+		t.Errorf("findInstalledPluginVersion() = %q, true, want the plugin not installed", version)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import "sync"
+
+// Plugins can be installed and upgraded concurrently. Downloads and
+// extraction run in parallel, but changes to the plugin store, the receipts
+// and the links in the bin dir are made under storeLock one at a time.
+var storeLock sync.Mutex
+
+// pluginLocks keeps concurrent installations of the same plugin apart, like
+// a dependency of two plugins that are installed at the same time.
+var pluginLocks = struct {
+	sync.Mutex
+	m map[string]*sync.Mutex
+}{m: make(map[string]*sync.Mutex)}
+
+// lockPlugin waits until no other installation of the plugin is running. The
+// returned function releases the lock.
+func lockPlugin(name string) func() {
+	pluginLocks.Lock()
+	l, ok := pluginLocks.m[name]
+	if !ok {
+		l = &sync.Mutex{}
+		pluginLocks.m[name] = l
+	}
+	pluginLocks.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// withStoreLock runs f while no other installation changes the plugin store.
+func withStoreLock(f func() error) error {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+	return f()
+}
@@ -0,0 +1,157 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/GoogleContainerTools/krew/pkg/download"
+	"github.com/GoogleContainerTools/krew/pkg/environment"
+	"github.com/GoogleContainerTools/krew/pkg/index"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// writeTestArchive writes a zip archive with an executable and returns its
+// sha256 sum.
+func writeTestArchive(t *testing.T, path string) string {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	h := &zip.FileHeader{Name: "plugin", Method: zip.Deflate}
+	h.SetMode(0755)
+	w, err := zw.CreateHeader(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("#!/bin/sh\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// setupKrewRoot creates a krew root and a plugin archive. The returned
+// function adds a platform with the archive to a plugin.
+func setupKrewRoot(t *testing.T) (environment.Paths, func(index.Plugin) index.Plugin, func()) {
+	tmpDir, err := ioutil.TempDir("", "krew-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("KREW_ROOT", tmpDir)
+	p := environment.MustGetKrewPaths()
+	for _, dir := range []string{p.InstallPath(), p.BinPath(), p.DownloadPath()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := filepath.Join(tmpDir, "plugin.zip")
+	sha := writeTestArchive(t, archive)
+	withPlatform := func(plugin index.Plugin) index.Plugin {
+		plugin.Spec.Platforms = []index.Platform{{
+			URI:      "file://" + filepath.ToSlash(archive),
+			Sha256:   sha,
+			Selector: &metav1.LabelSelector{},
+			Files:    []index.FileOperation{{From: "*", To: "."}},
+			Bin:      "plugin",
+		}}
+		return plugin
+	}
+	return p, withPlatform, func() {
+		os.Unsetenv("KREW_ROOT")
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestInstall_concurrent(t *testing.T) {
+	p, withPlatform, cleanup := setupKrewRoot(t)
+	defer cleanup()
+	dep := withPlatform(testPlugin("dep", "v1.0.0"))
+	plugins := []index.Plugin{
+		withPlatform(testPlugin("a", "v1.0.0", index.Dependency{Name: "dep"})),
+		withPlatform(testPlugin("b", "v1.0.0", index.Dependency{Name: "dep"})),
+		withPlatform(testPlugin("c", "v1.0.0", index.Dependency{Name: "dep"})),
+	}
+
+	var installedDeps int32
+	opts := InstallOpts{
+		Load: func(_, name string) (index.Plugin, error) { return dep, nil },
+		InstalledDependency: func(name string) {
+			atomic.AddInt32(&installedDeps, 1)
+		},
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(plugins))
+	for i := range plugins {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = Install(context.Background(), p, plugins[i], "default", opts)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Install(%s) error = %v", plugins[i].Name, err)
+		}
+	}
+	if installedDeps != 1 {
+		t.Errorf("dependency was reported installed %d times, want once", installedDeps)
+	}
+	for _, name := range []string{"a", "b", "c", "dep"} {
+		if version, ok, err := InstalledVersion(p, name); err != nil || !ok || version != "v1.0.0" {
+			t.Errorf("InstalledVersion(%s) = %q, %v, %v; want v1.0.0", name, version, ok, err)
+		}
+	}
+}
+
+func TestInstall_pluginFetcher(t *testing.T) {
+	p, withPlatform, cleanup := setupKrewRoot(t)
+	defer cleanup()
+	dep := withPlatform(testPlugin("dep", "v1.0.0"))
+	plugin := withPlatform(testPlugin("a", "v1.0.0"))
+	schemes := download.NewSchemes(download.NewHTTPFetcher())
+	opts := InstallOpts{
+		Load:          func(_, name string) (index.Plugin, error) { return dep, nil },
+		Downloader:    download.Downloader{Fetcher: schemes.Restrict(download.DefaultIndexSchemes)},
+		PluginFetcher: schemes,
+	}
+
+	// The plugin can use a file URI, its dependencies from the index can't.
+	if err := Install(context.Background(), p, plugin, "default", opts); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	withDep := withPlatform(testPlugin("b", "v1.0.0", index.Dependency{Name: "dep"}))
+	if err := Install(context.Background(), p, withDep, "default", opts); err == nil {
+		t.Error("Install() expected an error for a dependency with a file URI")
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Platform labels that krew computes for matching platform selectors.
+const (
+	// LabelOS is the GOOS of krew, like linux.
+	LabelOS = "os"
+	// LabelArch is the GOARCH of krew, like amd64.
+	LabelArch = "arch"
+	// LabelLibc is the C library on linux, glibc or musl.
+	LabelLibc = "libc"
+	// LabelArmVariant is the ARM architecture version on linux, like v7.
+	LabelArmVariant = "arm-variant"
+	// LabelKubectlMinor is the minor version of the kubectl client, like 12.
+	LabelKubectlMinor = "kubectl-minor"
+)
+
+var (
+	customLabelsMu sync.Mutex
+	customLabels   labels.Set
+
+	systemLabelsOnce sync.Once
+	systemLabels     *lazyLabels
+)
+
+// SetCustomPlatformLabels sets labels that are matched against platform
+// selectors in addition to the ones krew computes. Custom labels override
+// computed labels with the same key.
+func SetCustomPlatformLabels(l map[string]string) {
+	customLabelsMu.Lock()
+	defer customLabelsMu.Unlock()
+	customLabels = labels.Set(l)
+}
+
+// PlatformLabels returns all labels of this system that platform selectors
+// are matched against.
+func PlatformLabels() map[string]string {
+	return platformLabels().All()
+}
+
+// listableLabels are labels that can list all their keys and values.
+type listableLabels interface {
+	labels.Labels
+	All() map[string]string
+}
+
+func platformLabels() listableLabels {
+	systemLabelsOnce.Do(func() {
+		systemLabels = newSystemLabels()
+	})
+	customLabelsMu.Lock()
+	defer customLabelsMu.Unlock()
+	if len(customLabels) == 0 {
+		return systemLabels
+	}
+	return &overlayLabels{top: customLabels, bottom: systemLabels}
+}
+
+// newSystemLabels returns the built-in labels. Labels that are expensive to
+// compute are only computed when a selector uses them.
+func newSystemLabels() *lazyLabels {
+	l := newLazyLabels(labels.Set{
+		LabelOS:   runtime.GOOS,
+		LabelArch: runtime.GOARCH,
+	})
+	if runtime.GOOS == "linux" {
+		l.add(LabelLibc, func() (string, bool) {
+			return detectLibc(filepath.Glob)
+		})
+		if runtime.GOARCH == "arm" || runtime.GOARCH == "arm64" {
+			l.add(LabelArmVariant, func() (string, bool) {
+				f, err := os.Open("/proc/cpuinfo")
+				if err != nil {
+					glog.V(2).Infof("Can't read cpuinfo, err: %v", err)
+					return "", false
+				}
+				defer f.Close()
+				return armVariant(f)
+			})
+		}
+	}
+	l.add(LabelKubectlMinor, kubectlMinor)
+	return l
+}
+
+// lazyLabels implements labels.Labels for labels that are computed on first
+// use.
+type lazyLabels struct {
+	mu      sync.Mutex
+	values  labels.Set
+	missing map[string]bool
+	funcs   map[string]func() (string, bool)
+}
+
+func newLazyLabels(static labels.Set) *lazyLabels {
+	values := make(labels.Set, len(static))
+	for k, v := range static {
+		values[k] = v
+	}
+	return &lazyLabels{
+		values:  values,
+		missing: make(map[string]bool),
+		funcs:   make(map[string]func() (string, bool)),
+	}
+}
+
+// add registers a function that computes the value of the label. It returns
+// false if the label does not apply to this system.
+func (l *lazyLabels) add(key string, f func() (string, bool)) {
+	l.funcs[key] = f
+}
+
+func (l *lazyLabels) lookup(key string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if v, ok := l.values[key]; ok {
+		return v, true
+	}
+	f, ok := l.funcs[key]
+	if !ok || l.missing[key] {
+		return "", false
+	}
+	v, ok := f()
+	glog.V(4).Infof("Computed platform label %s=%q (set=%v)", key, v, ok)
+	if !ok {
+		l.missing[key] = true
+		return "", false
+	}
+	l.values[key] = v
+	return v, true
+}
+
+// String returns the labels that are computed so far.
+func (l *lazyLabels) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.values.String()
+}
+
+// Has implements labels.Labels.
+func (l *lazyLabels) Has(key string) bool {
+	_, ok := l.lookup(key)
+	return ok
+}
+
+// Get implements labels.Labels.
+func (l *lazyLabels) Get(key string) string {
+	v, _ := l.lookup(key)
+	return v
+}
+
+// All computes all labels.
+func (l *lazyLabels) All() map[string]string {
+	for key := range l.funcs {
+		l.lookup(key)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	all := make(map[string]string, len(l.values))
+	for k, v := range l.values {
+		all[k] = v
+	}
+	return all
+}
+
+// overlayLabels returns labels from top before looking them up in bottom.
+type overlayLabels struct {
+	top    labels.Set
+	bottom *lazyLabels
+}
+
+// Has implements labels.Labels.
+func (o *overlayLabels) Has(key string) bool { return o.top.Has(key) || o.bottom.Has(key) }
+
+// Get implements labels.Labels.
+func (o *overlayLabels) Get(key string) string {
+	if o.top.Has(key) {
+		return o.top.Get(key)
+	}
+	return o.bottom.Get(key)
+}
+
+// String returns the custom labels and the built-in labels that are computed
+// so far.
+func (o *overlayLabels) String() string {
+	return o.top.String() + "," + o.bottom.String()
+}
+
+// All computes all labels.
+func (o *overlayLabels) All() map[string]string {
+	all := o.bottom.All()
+	for k, v := range o.top {
+		all[k] = v
+	}
+	return all
+}
+
+// detectLibc checks for the musl dynamic loader, which musl based
+// distributions like Alpine ship instead of the glibc one.
+func detectLibc(glob func(string) ([]string, error)) (string, bool) {
+	if m, err := glob("/lib/ld-musl-*.so.1"); err == nil && len(m) > 0 {
+		return "musl", true
+	}
+	return "glibc", true
+}
+
+var cpuArchitectureRegexp = regexp.MustCompile(`^CPU architecture\s*:\s*(\d+)`)
+
+// armVariant reads the ARM architecture version from /proc/cpuinfo.
+func armVariant(cpuinfo io.Reader) (string, bool) {
+	s := bufio.NewScanner(cpuinfo)
+	for s.Scan() {
+		if m := cpuArchitectureRegexp.FindStringSubmatch(s.Text()); m != nil {
+			return "v" + m[1], true
+		}
+	}
+	return "", false
+}
+
+// kubectlMinor runs the kubectl client that invoked krew to get its minor
+// version.
+func kubectlMinor() (string, bool) {
+	kubectl := os.Getenv("KUBECTL_PLUGINS_CALLER")
+	if kubectl == "" {
+		kubectl = "kubectl"
+	}
+	out, err := exec.Command(kubectl, "version", "--client", "-o", "json").Output()
+	if err != nil {
+		glog.V(2).Infof("Can't get the kubectl version, err: %v", err)
+		return "", false
+	}
+	minor, err := parseKubectlMinor(out)
+	if err != nil {
+		glog.V(2).Infof("Can't parse the kubectl version, err: %v", err)
+		return "", false
+	}
+	return minor, true
+}
+
+var minorRegexp = regexp.MustCompile(`^\d+`)
+
+// parseKubectlMinor parses the output of "kubectl version --client -o json".
+// Vendor builds report minor versions like "12+", the suffix is dropped.
+func parseKubectlMinor(out []byte) (string, error) {
+	var v struct {
+		ClientVersion struct {
+			Minor string `json:"minor"`
+		} `json:"clientVersion"`
+	}
+	if err := json.Unmarshal(out, &v); err != nil {
+		return "", fmt.Errorf("failed to decode kubectl version, err: %v", err)
+	}
+	minor := minorRegexp.FindString(strings.TrimSpace(v.ClientVersion.Minor))
+	if minor == "" {
+		return "", fmt.Errorf("unexpected minor version %q", v.ClientVersion.Minor)
+	}
+	return minor, nil
+}
@@ -0,0 +1,124 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func Test_lazyLabels(t *testing.T) {
+	var calls int
+	l := newLazyLabels(labels.Set{"os": "linux"})
+	l.add("libc", func() (string, bool) {
+		calls++
+		return "musl", true
+	})
+	l.add("absent", func() (string, bool) {
+		calls++
+		return "", false
+	})
+
+	sel, err := labels.Parse("os=linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sel.Matches(l) || calls != 0 {
+		t.Fatalf("static selector matched=%v with %d lazy calls, want match without calls", sel.Matches(l), calls)
+	}
+
+	sel, err = labels.Parse("libc=musl,!absent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if !sel.Matches(l) {
+			t.Errorf("selector %s did not match", sel)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("lazy labels were computed %d times, want 2", calls)
+	}
+
+	want := map[string]string{"os": "linux", "libc": "musl"}
+	if got := l.All(); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() = %v, want %v", got, want)
+	}
+}
+
+func Test_overlayLabels(t *testing.T) {
+	o := &overlayLabels{
+		top:    labels.Set{"libc": "glibc", "team": "platform"},
+		bottom: newLazyLabels(labels.Set{"os": "linux", "libc": "musl"}),
+	}
+	want := map[string]string{"os": "linux", "libc": "glibc", "team": "platform"}
+	if got := o.All(); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() = %v, want %v", got, want)
+	}
+	if got := o.Get("libc"); got != "glibc" {
+		t.Errorf("Get(libc) = %q, want custom label to win", got)
+	}
+}
+
+func Test_detectLibc(t *testing.T) {
+	musl := func(string) ([]string, error) { return []string{"/lib/ld-musl-x86_64.so.1"}, nil }
+	none := func(string) ([]string, error) { return nil, nil }
+	if got, _ := detectLibc(musl); got != "musl" {
+		t.Errorf("detectLibc() = %q, want musl", got)
+	}
+	if got, _ := detectLibc(none); got != "glibc" {
+		t.Errorf("detectLibc() = %q, want glibc", got)
+	}
+}
+
+func Test_armVariant(t *testing.T) {
+	cpuinfo := `processor	: 0
+model name	: ARMv7 Processor rev 4 (v7l)
+BogoMIPS	: 38.40
+CPU architecture: 7
+CPU variant	: 0x0
+`
+	if got, ok := armVariant(strings.NewReader(cpuinfo)); !ok || got != "v7" {
+		t.Errorf("armVariant() = %q, %v; want v7", got, ok)
+	}
+	if got, ok := armVariant(strings.NewReader("processor	: 0\n")); ok {
+		t.Errorf("armVariant() = %q, want not found", got)
+	}
+}
+
+func Test_parseKubectlMinor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: `{"clientVersion": {"major": "1", "minor": "12"}}`, want: "12"},
+		{in: `{"clientVersion": {"major": "1", "minor": "11+"}}`, want: "11"},
+		{in: `{"clientVersion": {}}`, wantErr: true},
+		{in: `Client Version: v1.12.0`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseKubectlMinor([]byte(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseKubectlMinor(%s) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("parseKubectlMinor(%s) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
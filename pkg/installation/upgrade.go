@@ -15,19 +15,42 @@
 package installation
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"io/ioutil"
+	"path/filepath"
 
+	"github.com/GoogleContainerTools/krew/pkg/download"
 	"github.com/GoogleContainerTools/krew/pkg/environment"
 	"github.com/GoogleContainerTools/krew/pkg/index"
+	"github.com/GoogleContainerTools/krew/pkg/receipt"
+	"github.com/GoogleContainerTools/krew/pkg/semver"
 	"github.com/golang/glog"
 )
 
+// UpgradeOpts changes how a plugin is upgraded.
+type UpgradeOpts struct {
+	// AllowDowngrade installs the version from the index even if it is older
+	// than the installed version.
+	AllowDowngrade bool
+	// Load loads the manifests of dependencies. Dependencies that the new
+	// version adds can't be installed without it.
+	Load PluginLoader
+	// Downloader downloads the archives of the plugin and its dependencies.
+	// Its label is set to the name of each plugin.
+	Downloader download.Downloader
+}
+
 // Upgrade will reinstall and delete the old plugin. The operation tries
 // to not get the plugin dir in a bad state if it fails during the process.
-func Upgrade(p environment.Paths, plugin index.Plugin, currentKrewVersion string) error {
+// The plugin is recorded as installed from the named index. Installing an
+// older version than the installed one fails with ErrIsDowngrade unless
+// AllowDowngrade is set. Missing dependencies of the new version are
+// installed first. Plugins can be upgraded concurrently.
+func Upgrade(ctx context.Context, p environment.Paths, plugin index.Plugin, indexName, currentKrewVersion string, opts UpgradeOpts) error {
+	defer lockPlugin(plugin.Name)()
 	oldVersion, ok, err := findInstalledPluginVersion(p.InstallPath(), p.BinPath(), plugin.Name)
 	if err != nil {
 		return fmt.Errorf("could not detect installed plugin oldVersion, err: %v", err)
@@ -36,20 +59,42 @@ func Upgrade(p environment.Paths, plugin index.Plugin, currentKrewVersion string
 		return fmt.Errorf("can't upgrade plugin %q, it is not installed", plugin.Name)
 	}
 
-	// Check allowed installation
-	newVersion, uri, fos, binName, err := getDownloadTarget(plugin, oldVersion == headVersion)
-	if oldVersion == newVersion && oldVersion != headVersion {
-		return ErrIsAlreadyUpgraded
+	if err := index.CheckKrewVersion(plugin); err != nil {
+		return err
 	}
+
+	// Check allowed installation
+	newVersion, verification, uris, fos, bins, bare, err := getDownloadTarget(plugin, oldVersion == headVersion)
 	if err != nil {
 		return fmt.Errorf("failed to get the current download target, err: %v", err)
 	}
+	if oldVersion != headVersion && newVersion != headVersion {
+		if err := checkVersionChange(oldVersion, newVersion, opts.AllowDowngrade); err != nil {
+			return err
+		}
+	}
+
+	glog.V(2).Infof("Resolving dependencies of plugin %s", plugin.Name)
+	pending, err := resolveDependencies(p, plugin, indexName, opts.Load)
+	if err != nil {
+		return fmt.Errorf("failed to resolve dependencies, err: %v", err)
+	}
+	// The plugin itself is the last pending installation.
+	for _, pi := range pending[:len(pending)-1] {
+		if err := index.CheckKrewVersion(pi.plugin); err != nil {
+			return err
+		}
+		err := installOne(ctx, opts.Downloader, p, pi.plugin, pi.indexName, false)
+		if err != nil && err != ErrIsAlreadyInstalled {
+			return fmt.Errorf("failed to install dependency %q, err: %v", pi.plugin.Name, err)
+		}
+	}
 
 	// Move head to save location
 	if oldVersion == headVersion {
 		oldHEADPath, newHEADPath := p.PluginVersionInstallPath(plugin.Name, headVersion), p.PluginVersionInstallPath(plugin.Name, headOldVersion)
 		glog.V(2).Infof("Move old HEAD from: %q to %q", oldHEADPath, newHEADPath)
-		if err = os.Rename(oldHEADPath, newHEADPath); err != nil {
+		if err := withStoreLock(func() error { return os.Rename(oldHEADPath, newHEADPath) }); err != nil {
 			return fmt.Errorf("failed to rename HEAD to HEAD-OLD, from %q to %q, err: %v", oldHEADPath, newHEADPath, err)
 		}
 		oldVersion = headOldVersion
@@ -57,15 +102,88 @@ func Upgrade(p environment.Paths, plugin index.Plugin, currentKrewVersion string
 
 	// Re-Install
 	glog.V(1).Infof("Installing new version %s", newVersion)
-	if err := install(plugin.Name, newVersion, uri, binName, p, fos); err != nil {
+	d := pluginDownloader(opts.Downloader, plugin.Name)
+	d.BareBinary = bare
+	if err := install(ctx, d, newVersion, uris, verification, bins, p, fos); err != nil {
 		return fmt.Errorf("failed to install new version, err: %v", err)
 	}
 
+	storeLock.Lock()
+	defer storeLock.Unlock()
+	if err := removeStaleLinks(p, plugin.Name, bins); err != nil {
+		return err
+	}
+
+	glog.V(3).Infof("Storing install receipt for plugin %s", plugin.Name)
+	if err := receipt.Store(receipt.New(plugin, indexName), p.PluginInstallReceiptPath(plugin.Name)); err != nil {
+		return fmt.Errorf("failed to store the install receipt, err: %v", err)
+	}
+
 	// Clean old installations
 	glog.V(4).Infof("Starting old version cleanup")
 	return removePluginVersionFromFS(p, plugin, newVersion, oldVersion, currentKrewVersion)
 }
 
+// Migrate replaces an installed plugin with the plugin that replaces it. The
+// replacement is installed before the old plugin is removed, so the old
+// plugin stays installed if the installation fails.
+func Migrate(ctx context.Context, p environment.Paths, oldName string, replacement index.Plugin, indexName string, opts InstallOpts) error {
+	glog.V(1).Infof("Migrating plugin %s to %s", oldName, replacement.Name)
+	if err := Install(ctx, p, replacement, indexName, opts); err != nil && err != ErrIsAlreadyInstalled {
+		return fmt.Errorf("failed to install replacement plugin %q, err: %v", replacement.Name, err)
+	}
+	if err := Remove(p, oldName); err != nil {
+		return fmt.Errorf("failed to remove plugin %q, err: %v", oldName, err)
+	}
+	return nil
+}
+
+// removeStaleLinks removes links of commands that the new version of the
+// plugin no longer provides.
+func removeStaleLinks(p environment.Paths, plugin string, bins []index.Binary) error {
+	links, err := findPluginLinks(p.InstallPath(), p.BinPath(), plugin)
+	if err != nil {
+		return fmt.Errorf("could not find symlinks of plugin, err: %v", err)
+	}
+	current := make(map[string]bool, len(bins))
+	for _, b := range bins {
+		current[pluginNameToBin(b.Name, isWindows())] = true
+	}
+	for _, l := range links {
+		if current[filepath.Base(l.path)] {
+			continue
+		}
+		glog.V(2).Infof("Removing link %q, the new version does not provide it", l.path)
+		if err := removeLink(l.path); err != nil {
+			return fmt.Errorf("could not remove stale symlink, err: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkVersionChange checks that newVersion can replace the installed
+// oldVersion. Plugins installed before versions were required live in
+// directories named by their sha256 sum, these can't be compared and are
+// always upgraded.
+func checkVersionChange(oldVersion, newVersion string, allowDowngrade bool) error {
+	oldV, err := semver.Parse(oldVersion)
+	if err != nil {
+		glog.V(2).Infof("Installed version %q is not a semantic version, upgrading", oldVersion)
+		return nil
+	}
+	newV, err := semver.Parse(newVersion)
+	if err != nil {
+		return fmt.Errorf("failed to parse the new version, err: %v", err)
+	}
+	switch c := semver.Compare(newV, oldV); {
+	case c == 0:
+		return ErrIsAlreadyUpgraded
+	case c < 0 && !allowDowngrade:
+		return ErrIsDowngrade
+	}
+	return nil
+}
+
 // removePluginVersionFromFS will remove a plugin directly if it not krew. Krew on Windows needs special care
 // because active directories can't be deleted. This method will unlink old krew versions and during next run clean
 // the directory.
@@ -0,0 +1,71 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package installation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleContainerTools/krew/pkg/index"
+)
+
+func Test_checkVersionChange(t *testing.T) {
+	tests := []struct {
+		name           string
+		oldVersion     string
+		newVersion     string
+		allowDowngrade bool
+		want           error
+	}{
+		{name: "upgrade", oldVersion: "v1.0.0", newVersion: "v1.1.0", want: nil},
+		{name: "prerelease to release", oldVersion: "v1.0.0-rc.1", newVersion: "v1.0.0", want: nil},
+		{name: "same version", oldVersion: "v1.0.0", newVersion: "v1.0.0", want: ErrIsAlreadyUpgraded},
+		{name: "downgrade", oldVersion: "v1.10.0", newVersion: "v1.9.0", want: ErrIsDowngrade},
+		{name: "allowed downgrade", oldVersion: "v1.10.0", newVersion: "v1.9.0", allowDowngrade: true, want: nil},
+		{name: "legacy sha256 directory", oldVersion: "deadbeef", newVersion: "v0.1.0", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkVersionChange(tt.oldVersion, tt.newVersion, tt.allowDowngrade); got != tt.want {
+				t.Errorf("checkVersionChange(%q, %q) = %v, want %v", tt.oldVersion, tt.newVersion, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpgrade_installsNewDependencies(t *testing.T) {
+	p, withPlatform, cleanup := setupKrewRoot(t)
+	defer cleanup()
+	if err := Install(context.Background(), p, withPlatform(testPlugin("a", "v1.0.0")), "default", InstallOpts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	dep := withPlatform(testPlugin("dep", "v1.0.0"))
+	newVersion := withPlatform(testPlugin("a", "v1.1.0", index.Dependency{Name: "dep"}))
+	opts := UpgradeOpts{Load: func(_, name string) (index.Plugin, error) { return dep, nil }}
+	if err := Upgrade(context.Background(), p, newVersion, "default", "", opts); err != nil {
+		t.Fatalf("Upgrade() error = %v", err)
+	}
+	for name, want := range map[string]string{"a": "v1.1.0", "dep": "v1.0.0"} {
+		if version, ok, err := InstalledVersion(p, name); err != nil || !ok || version != want {
+			t.Errorf("InstalledVersion(%s) = %q, %v, %v; want %s", name, version, ok, err, want)
+		}
+	}
+
+	tighter := withPlatform(testPlugin("a", "v1.2.0", index.Dependency{Name: "dep", Version: ">=v2.0.0"}))
+	if err := Upgrade(context.Background(), p, tighter, "default", "", opts); err == nil {
+		t.Error("Upgrade() expected an error for an installed dependency that is too old")
+	}
+}
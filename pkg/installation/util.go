@@ -17,29 +17,26 @@ package installation
 import (
 	"fmt"
 	"io/ioutil"
-	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 
 	"github.com/golang/glog"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 
+	"github.com/GoogleContainerTools/krew/pkg/download"
+	"github.com/GoogleContainerTools/krew/pkg/environment"
 	"github.com/GoogleContainerTools/krew/pkg/index"
-	"github.com/GoogleContainerTools/krew/pkg/pathutil"
+	"github.com/GoogleContainerTools/krew/pkg/semver"
 )
 
-// GetMatchingPlatform TODO(lbb)
+// GetMatchingPlatform returns the first platform of the plugin whose selector
+// matches the labels of this system, see PlatformLabels.
 func GetMatchingPlatform(i index.Plugin) (index.Platform, bool, error) {
-	return matchPlatformToSystemEnvs(i, runtime.GOOS, runtime.GOARCH)
+	return matchPlatform(i, platformLabels())
 }
 
-func matchPlatformToSystemEnvs(i index.Plugin, os, arch string) (index.Platform, bool, error) {
-	envLabels := labels.Set{
-		"os":   os,
-		"arch": arch,
-	}
+func matchPlatform(i index.Plugin, envLabels labels.Labels) (index.Platform, bool, error) {
 	glog.V(2).Infof("Matching platform for labels(%v)", envLabels)
 	for i, platform := range i.Spec.Platforms {
 		sel, err := metav1.LabelSelectorAsSelector(platform.Selector)
@@ -54,65 +51,77 @@ func matchPlatformToSystemEnvs(i index.Plugin, os, arch string) (index.Platform,
 	return index.Platform{}, false, nil
 }
 
+// findInstalledPluginVersion returns the installed version of the plugin. The
+// command with the name of the plugin only counts if it links into the
+// installation of the plugin, another plugin may provide a command with that
+// name.
 func findInstalledPluginVersion(installPath, binDir, pluginName string) (name string, installed bool, err error) {
 	if !index.IsSafePluginName(pluginName) {
 		return "", false, fmt.Errorf("the plugin name %q is not allowed", pluginName)
 	}
 	glog.V(3).Infof("Searching for installed versions of %s in %q", pluginName, binDir)
-	link, err := os.Readlink(filepath.Join(binDir, pluginNameToBin(pluginName, isWindows())))
-	if os.IsNotExist(err) {
-		return "", false, nil
-	} else if err != nil {
-		return "", false, fmt.Errorf("could not read plugin link, err: %v", err)
-	}
-
-	if !filepath.IsAbs(link) {
-		if link, err = filepath.Abs(filepath.Join(binDir, link)); err != nil {
-			return "", true, fmt.Errorf("failed to get the absolute path for the link of %q, err: %v", link, err)
-		}
-	}
-
-	name, err = pluginVersionFromPath(installPath, link)
+	link, ok, err := readPluginLink(installPath, filepath.Join(binDir, pluginNameToBin(pluginName, isWindows())))
 	if err != nil {
-		return "", true, fmt.Errorf("cloud not parse plugin version, err: %v", err)
+		return "", false, err
 	}
-	return name, true, nil
-}
-
-func pluginVersionFromPath(installPath, pluginPath string) (string, error) {
-	// plugin path: {install_path}/{plugin_name}/{version}/...
-	elems, ok := pathutil.IsSubPath(installPath, pluginPath)
-	if !ok || len(elems) < 2 {
-		return "", fmt.Errorf("failed to get the version from execution path=%q, with install path=%q", pluginPath, installPath)
+	if ok && link.plugin == pluginName {
+		return link.version, true, nil
+	}
+	// Plugins with several binaries don't need a command with their name.
+	links, err := findPluginLinks(installPath, binDir, pluginName)
+	if err != nil || len(links) == 0 {
+		return "", false, err
 	}
-	return elems[1], nil
+	return links[0].version, true, nil
 }
 
-func getPluginVersion(p index.Platform, forceHEAD bool) (version, uri string, err error) {
+// getPluginVersion returns the version that names the installation directory,
+// the sha256 sum to verify the download with and the URI to download from.
+// HEAD installations are not verified and have no sha256 sum.
+func getPluginVersion(p index.Platform, specVersion string, forceHEAD bool) (version, sha256, uri string, err error) {
 	if (forceHEAD && p.Head != "") || (p.Head != "" && p.Sha256 == "" && p.URI == "") {
-		return headVersion, p.Head, nil
+		return headVersion, "", p.Head, nil
 	}
 	if forceHEAD && p.Head == "" {
-		return "", "", fmt.Errorf("can't force HEAD, with no HEAD specified")
+		return "", "", "", fmt.Errorf("can't force HEAD, with no HEAD specified")
+	}
+	v, err := semver.Parse(specVersion)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to parse the plugin version, err: %v", err)
 	}
-	return strings.ToLower(p.Sha256), p.URI, nil
+	return v.String(), strings.ToLower(p.Sha256), p.URI, nil
 }
 
-func getDownloadTarget(index index.Plugin, forceHEAD bool) (version, uri string, fos []index.FileOperation, bin string, err error) {
+// getDownloadTarget returns what to download for the matching platform of a
+// plugin. The URIs are the manifest URI followed by its mirrors. HEAD
+// downloads have no mirrors and are only verified if the plugin has a public
+// key. bare is set if the download is a single executable.
+func getDownloadTarget(index index.Plugin, forceHEAD bool) (version string, verification download.Verification, uris []string, fos []index.FileOperation, bins []index.Binary, bare bool, err error) {
 	p, ok, err := GetMatchingPlatform(index)
 	if err != nil {
-		return "", "", nil, p.Bin, fmt.Errorf("failed to get matching platforms, err: %v", err)
+		return "", verification, nil, nil, nil, false, fmt.Errorf("failed to get matching platforms, err: %v", err)
 	}
 	if !ok {
-		return "", "", nil, p.Bin, fmt.Errorf("no matching platform found")
+		return "", verification, nil, nil, nil, false, fmt.Errorf("no matching platform found")
 	}
-	version, uri, err = getPluginVersion(p, forceHEAD)
+	version, sha256, uri, err := getPluginVersion(p, index.Spec.Version, forceHEAD)
 	if err != nil {
-		return "", "", nil, p.Bin, fmt.Errorf("failed to get the plugin version, err: %v", err)
+		return "", verification, nil, nil, nil, false, fmt.Errorf("failed to get the plugin version, err: %v", err)
 	}
 	glog.V(4).Infof("Matching plugin version is %s", version)
 
-	return version, uri, p.Files, p.Bin, nil
+	verification = download.Verification{Sha256: sha256, PublicKey: index.Spec.PublicKey}
+	uris = []string{uri}
+	if version != headVersion {
+		verification.Sha512 = strings.ToLower(p.Sha512)
+		uris = append(uris, p.Mirrors...)
+	}
+	return version, verification, uris, p.Files, p.Executables(index.Name), p.BareBinary, nil
+}
+
+// InstalledVersion returns the installed version of a plugin.
+func InstalledVersion(p environment.Paths, pluginName string) (version string, installed bool, err error) {
+	return findInstalledPluginVersion(p.InstallPath(), p.BinPath(), pluginName)
 }
 
 // ListInstalledPlugins returns a list of all name:version for all plugins.
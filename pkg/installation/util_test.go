@@ -23,9 +23,10 @@ import (
 	"github.com/GoogleContainerTools/krew/pkg/index"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
-func Test_matchPlatformToSystemEnvs(t *testing.T) {
+func Test_matchPlatform(t *testing.T) {
 	matchingPlatform := index.Platform{
 		Head: "A",
 		Selector: &v1.LabelSelector{
@@ -92,7 +93,7 @@ func Test_matchPlatformToSystemEnvs(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotPlatform, gotFound, err := matchPlatformToSystemEnvs(tt.args.i, "foo", "amdBar")
+			gotPlatform, gotFound, err := matchPlatform(tt.args.i, labels.Set{"os": "foo", "arch": "amdBar"})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetMatchingPlatform() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -109,13 +110,15 @@ func Test_matchPlatformToSystemEnvs(t *testing.T) {
 
 func Test_getPluginVersion(t *testing.T) {
 	type args struct {
-		p         index.Platform
-		forceHEAD bool
+		p           index.Platform
+		specVersion string
+		forceHEAD   bool
 	}
 	tests := []struct {
 		name        string
 		args        args
 		wantVersion string
+		wantSha256  string
 		wantURI     string
 		wantErr     bool
 	}{
@@ -137,12 +140,27 @@ func Test_getPluginVersion(t *testing.T) {
 				p: index.Platform{
 					Head:   "https://head.git",
 					URI:    "https://uri.git",
-					Sha256: "deadbeef",
+					Sha256: "DEADBEEF",
 				},
-				forceHEAD: false,
+				specVersion: "1.2.3",
+				forceHEAD:   false,
 			},
-			wantVersion: "deadbeef",
+			wantVersion: "v1.2.3",
+			wantSha256:  "deadbeef",
 			wantURI:     "https://uri.git",
+		}, {
+			name: "Invalid version",
+			args: args{
+				p: index.Platform{
+					URI:    "https://uri.git",
+					Sha256: "deadbeef",
+				},
+				specVersion: "latest",
+				forceHEAD:   false,
+			},
+			wantErr:     true,
+			wantVersion: "",
+			wantURI:     "",
 		}, {
 			name: "Get HEAD force",
 			args: args{
@@ -172,13 +190,16 @@ func Test_getPluginVersion(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotVersion, gotURI, err := getPluginVersion(tt.args.p, tt.args.forceHEAD)
+			gotVersion, gotSha256, gotURI, err := getPluginVersion(tt.args.p, tt.args.specVersion, tt.args.forceHEAD)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getPluginVersion() gotVersion = %v, want %v, got err = %v want err = %v", gotVersion, tt.wantVersion, err, tt.wantErr)
 			}
 			if gotVersion != tt.wantVersion {
 				t.Errorf("getPluginVersion() gotVersion = %v, want %v", gotVersion, tt.wantVersion)
 			}
+			if gotSha256 != tt.wantSha256 {
+				t.Errorf("getPluginVersion() gotSha256 = %v, want %v", gotSha256, tt.wantSha256)
+			}
 			if gotURI != tt.wantURI {
 				t.Errorf("getPluginVersion() gotURI = %v, want %v", gotURI, tt.wantURI)
 			}
@@ -207,9 +228,9 @@ func Test_getDownloadTarget(t *testing.T) {
 		name        string
 		args        args
 		wantVersion string
-		wantURI     string
+		wantURIs    []string
 		wantFos     []index.FileOperation
-		wantBin     string
+		wantBins    []index.Binary
 		wantErr     bool
 	}{
 		{
@@ -217,6 +238,7 @@ func Test_getDownloadTarget(t *testing.T) {
 			args: args{
 				forceHEAD: true,
 				index: index.Plugin{
+					ObjectMeta: v1.ObjectMeta{Name: "foo"},
 					Spec: index.PluginSpec{
 						Platforms: []index.Platform{
 							matchingPlatform,
@@ -233,9 +255,34 @@ func Test_getDownloadTarget(t *testing.T) {
 				},
 			},
 			wantVersion: "HEAD",
-			wantURI:     "https://head.git",
+			wantURIs:    []string{"https://head.git"},
+			wantFos:     nil,
+			wantBins:    []index.Binary{{Bin: "kubectl-foo", Name: "foo"}},
+			wantErr:     false,
+		}, {
+			name: "Platform with several binaries",
+			args: args{
+				forceHEAD: true,
+				index: index.Plugin{
+					ObjectMeta: v1.ObjectMeta{Name: "foo"},
+					Spec: index.PluginSpec{
+						Platforms: []index.Platform{
+							{
+								Head:     "https://head.git",
+								Selector: matchingPlatform.Selector,
+								Binaries: []index.Binary{
+									{Bin: "bin/foo", Name: "foo"},
+									{Bin: "bin/foo-ctl", Name: "foo-ctl"},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantVersion: "HEAD",
+			wantURIs:    []string{"https://head.git"},
 			wantFos:     nil,
-			wantBin:     "kubectl-foo",
+			wantBins:    []index.Binary{{Bin: "bin/foo", Name: "foo"}, {Bin: "bin/foo-ctl", Name: "foo-ctl"}},
 			wantErr:     false,
 		}, {
 			name: "No Matching Platform",
@@ -257,15 +304,15 @@ func Test_getDownloadTarget(t *testing.T) {
 				},
 			},
 			wantVersion: "",
-			wantURI:     "",
+			wantURIs:    nil,
 			wantFos:     nil,
-			wantBin:     "",
+			wantBins:    nil,
 			wantErr:     true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotVersion, gotURI, gotFos, bin, err := getDownloadTarget(tt.args.index, tt.args.forceHEAD)
+			gotVersion, _, gotURIs, gotFos, bins, _, err := getDownloadTarget(tt.args.index, tt.args.forceHEAD)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getDownloadTarget() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -273,11 +320,11 @@ func Test_getDownloadTarget(t *testing.T) {
 			if gotVersion != tt.wantVersion {
 				t.Errorf("getDownloadTarget() gotVersion = %v, want %v", gotVersion, tt.wantVersion)
 			}
-			if bin != tt.wantBin {
-				t.Errorf("getDownloadTarget() bin = %v, want %v", bin, tt.wantBin)
+			if !reflect.DeepEqual(bins, tt.wantBins) {
+				t.Errorf("getDownloadTarget() bins = %v, want %v", bins, tt.wantBins)
 			}
-			if gotURI != tt.wantURI {
-				t.Errorf("getDownloadTarget() gotURI = %v, want %v", gotURI, tt.wantURI)
+			if !reflect.DeepEqual(gotURIs, tt.wantURIs) {
+				t.Errorf("getDownloadTarget() gotURIs = %v, want %v", gotURIs, tt.wantURIs)
 			}
 			if !reflect.DeepEqual(gotFos, tt.wantFos) {
 				t.Errorf("getDownloadTarget() gotFos = %v, want %v", gotFos, tt.wantFos)
@@ -355,38 +402,3 @@ func testdataPath(t *testing.T) string {
 	}
 	return filepath.Join(pwd, "testdata")
 }
-
-func Test_pluginVersionFromPath(t *testing.T) {
-	type args struct {
-		installPath string
-		pluginPath  string
-	}
-	tests := []struct {
-		name    string
-		args    args
-		want    string
-		wantErr bool
-	}{
-		{
-			name: "normal version",
-			args: args{
-				installPath: filepath.FromSlash("install/"),
-				pluginPath:  filepath.FromSlash("install/foo/HEAD/kubectl-foo"),
-			},
-			want:    "HEAD",
-			wantErr: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := pluginVersionFromPath(tt.args.installPath, tt.args.pluginPath)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("pluginVersionFromPath() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("pluginVersionFromPath() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
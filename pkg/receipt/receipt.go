@@ -0,0 +1,92 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package receipt reads and writes the receipts that krew keeps for every
+// installed plugin.
+package receipt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/krew/pkg/index"
+
+	"github.com/ghodss/yaml"
+)
+
+// New returns a receipt for a plugin installed from the given index.
+func New(plugin index.Plugin, indexName string) index.Receipt {
+	return index.Receipt{
+		Plugin: plugin,
+		Status: index.ReceiptStatus{
+			Source: index.SourceIndex{Name: indexName},
+		},
+	}
+}
+
+// Store saves the receipt at the destination file.
+func Store(receipt index.Receipt, dest string) error {
+	yamlBytes, err := yaml.Marshal(receipt)
+	if err != nil {
+		return fmt.Errorf("failed to convert receipt to yaml, err: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create receipt directory, err: %v", err)
+	}
+	if err := ioutil.WriteFile(dest, yamlBytes, 0644); err != nil {
+		return fmt.Errorf("failed to write plugin receipt %q, err: %v", dest, err)
+	}
+	return nil
+}
+
+// Load reads the receipt at the given path. When the receipt is not found, it
+// returns an error that can be checked with os.IsNotExist.
+func Load(path string) (index.Receipt, error) {
+	var receipt index.Receipt
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return receipt, err
+	} else if err != nil {
+		return receipt, fmt.Errorf("failed to read plugin receipt %q, err: %v", path, err)
+	}
+	if err := yaml.Unmarshal(raw, &receipt); err != nil {
+		return receipt, fmt.Errorf("failed to decode plugin receipt %q, err: %v", path, err)
+	}
+	return receipt, nil
+}
+
+// LoadAll reads all receipts in the directory. A missing directory has no
+// receipts.
+func LoadAll(dir string) ([]index.Receipt, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read receipts directory %q, err: %v", dir, err)
+	}
+	var receipts []index.Receipt
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".yaml" {
+			continue
+		}
+		r, err := Load(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts, nil
+}
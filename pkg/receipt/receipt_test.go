@@ -0,0 +1,94 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/krew/pkg/index"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStoreAndLoad(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "krew-receipt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	plugin := index.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo"},
+		Spec:       index.PluginSpec{ShortDescription: "short"},
+	}
+	dest := filepath.Join(tempDir, "receipts", "foo.yaml")
+	if err := Store(New(plugin, "custom"), dest); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+
+	got, err := Load(dest)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got.Name != "foo" {
+		t.Errorf("Load() name = %q, want %q", got.Name, "foo")
+	}
+	if got.Spec.ShortDescription != "short" {
+		t.Errorf("Load() shortDescription = %q, want %q", got.Spec.ShortDescription, "short")
+	}
+	if got.Status.Source.Name != "custom" {
+		t.Errorf("Load() source index = %q, want %q", got.Status.Source.Name, "custom")
+	}
+}
+
+func TestLoad_notExists(t *testing.T) {
+	if _, err := Load(filepath.FromSlash("/non/existing/receipt.yaml")); !os.IsNotExist(err) {
+		t.Fatalf("Load() error = %v, want IsNotExist", err)
+	}
+}
+
+func TestLoadAll(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "krew-receipt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{"foo", "bar"} {
+		plugin := index.Plugin{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if err := Store(New(plugin, "default"), filepath.Join(tempDir, name+".yaml")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "README"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadAll(tempDir)
+	if err != nil {
+		t.Fatalf("LoadAll() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("LoadAll() returned %d receipts, want 2", len(got))
+	}
+
+	got, err = LoadAll(filepath.Join(tempDir, "not-exists"))
+	if err != nil || len(got) != 0 {
+		t.Fatalf("LoadAll() of missing dir = %v, %v; want no receipts", got, err)
+	}
+}
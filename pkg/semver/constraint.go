@@ -0,0 +1,109 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operators are sorted so that longer operators are matched first.
+var operators = []string{">=", "<=", "!=", ">", "<", "=", "^", "~"}
+
+// Constraint is a set of conditions that a version must all satisfy, like
+// ">=v1.2.0, <v2.0.0".
+type Constraint struct {
+	terms []term
+	raw   string
+}
+
+type term struct {
+	op      string
+	version Version
+}
+
+// ParseConstraint parses a comma separated list of conditions. Each condition
+// is a version prefixed with one of the operators =, !=, >, >=, <, <=, ~ or ^.
+// A version without an operator must match exactly. "~v1.2.3" allows patch
+// releases of v1.2 and "^v1.2.3" allows all releases that don't change the
+// first non-zero version component. An empty constraint matches all versions.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	if strings.TrimSpace(s) == "" {
+		return c, nil
+	}
+	for _, cond := range strings.Split(s, ",") {
+		cond = strings.TrimSpace(cond)
+		op := "="
+		for _, o := range operators {
+			if strings.HasPrefix(cond, o) {
+				op = o
+				cond = strings.TrimSpace(strings.TrimPrefix(cond, o))
+				break
+			}
+		}
+		v, err := Parse(cond)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q, err: %v", s, err)
+		}
+		c.terms = append(c.terms, term{op: op, version: v})
+	}
+	return c, nil
+}
+
+// Check reports whether the version satisfies the constraint.
+func (c Constraint) Check(v Version) bool {
+	for _, t := range c.terms {
+		if !t.check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the constraint as it was parsed.
+func (c Constraint) String() string { return c.raw }
+
+func (t term) check(v Version) bool {
+	cmp := Compare(v, t.version)
+	switch t.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "~":
+		return cmp >= 0 && v.Major == t.version.Major && v.Minor == t.version.Minor
+	case "^":
+		if cmp < 0 || v.Major != t.version.Major {
+			return false
+		}
+		if t.version.Major == 0 && v.Minor != t.version.Minor {
+			return false
+		}
+		if t.version.Major == 0 && t.version.Minor == 0 && v.Patch != t.version.Patch {
+			return false
+		}
+		return true
+	}
+	return false
+}
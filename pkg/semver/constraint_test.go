@@ -0,0 +1,67 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import "testing"
+
+func TestConstraint_Check(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "v0.0.1", true},
+		{"v1.2.3", "v1.2.3", true},
+		{"=v1.2.3", "v1.2.4", false},
+		{"!=v1.2.3", "v1.2.4", true},
+		{">v1.2.3", "v1.2.3", false},
+		{">= v1.2.3", "v1.2.3", true},
+		{"<v1.2.3", "v1.2.3-rc.1", true},
+		{"<=v1.2.3", "v1.3.0", false},
+		{">=v1.0.0, <v2.0.0", "v1.9.9", true},
+		{">=v1.0.0, <v2.0.0", "v2.0.0", false},
+		{"~v1.2.3", "v1.2.9", true},
+		{"~v1.2.3", "v1.3.0", false},
+		{"^v1.2.3", "v1.9.0", true},
+		{"^v1.2.3", "v2.0.0", false},
+		{"^v1.2.3", "v1.2.2", false},
+		{"^v0.2.3", "v0.2.9", true},
+		{"^v0.2.3", "v0.3.0", false},
+		{"^v0.0.3", "v0.0.4", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint(%q) error = %v", tt.constraint, err)
+			}
+			v, err := Parse(tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Check(v); got != tt.want {
+				t.Errorf("%q.Check(%s) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConstraint_invalid(t *testing.T) {
+	for _, s := range []string{">=", "v1", ">=v1.0.0,", "=>v1.0.0", "latest"} {
+		if _, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q) expected error", s)
+		}
+	}
+}
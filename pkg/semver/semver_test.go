@@ -0,0 +1,88 @@
+// Copyright © 2018 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "v1.2.3", want: "v1.2.3"},
+		{in: "1.2.3", want: "v1.2.3"},
+		{in: "v0.0.1-alpha.1+build.5", want: "v0.0.1-alpha.1+build.5"},
+		{in: "v1.2", wantErr: true},
+		{in: "v01.2.3", wantErr: true},
+		{in: "v1.2.3-", wantErr: true},
+		{in: "master", wantErr: true},
+		{in: "deadbeef", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Sorted in ascending precedence, taken from https://semver.org.
+	ordered := []string{
+		"v0.9.0",
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.0.1",
+		"v1.1.0",
+		"v2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, err := Parse(ordered[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Parse(ordered[j])
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := compareUint(uint64(i), uint64(j))
+			if got := Compare(a, b); got != want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestCompare_ignoresBuild(t *testing.T) {
+	a, _ := Parse("v1.0.0+1")
+	b, _ := Parse("v1.0.0+2")
+	if got := Compare(a, b); got != 0 {
+		t.Errorf("Compare(%s, %s) = %d, want 0", a, b, got)
+	}
+}